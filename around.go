@@ -0,0 +1,76 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Around fetches up to before nodes before the anchor cursor and up to after nodes after it, the anchor itself excluded,
+// and merges them into one connection in order, e.g. to center a list on a deep linked node.
+// The flags of PageInfo tell whether there are more nodes before the first one and after the last one.
+// The anchor is usually built with cursor.KeysetCursorForNode for the same orderBys, encoded like the other cursors.
+// The total count, facets and distinct values are computed once, EffectiveLimit is the sum of the effective before and after.
+func Around[T any](ctx context.Context, p Pagination[T], anchor string, before, after int, orderBys ...OrderBy) (*Connection[T], error) {
+	if before < 0 || after < 0 {
+		return nil, errors.New("before and after must be non-negative integers")
+	}
+
+	// the aggregates are the same on both sides, so only the side after the anchor computes them
+	prevSkip := GetSkip(ctx)
+	prevSkip.TotalCount, prevSkip.Facets, prevSkip.DistinctValues = true, true, true
+	prev, err := p.Paginate(WithSkip(ctx, prevSkip), &PaginateRequest[T]{
+		Before:   &anchor,
+		Last:     &before,
+		OrderBys: orderBys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := p.Paginate(ctx, &PaginateRequest[T]{
+		After:    &anchor,
+		First:    &after,
+		OrderBys: orderBys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Connection[T]{
+		TotalCount:        next.TotalCount,
+		TotalCountIsExact: next.TotalCountIsExact,
+		Facets:            next.Facets,
+		DistinctValues:    next.DistinctValues,
+		DeletedCount:      next.DeletedCount,
+		DroppedNodes:      prev.DroppedNodes + next.DroppedNodes,
+		EffectiveLimit:    prev.EffectiveLimit + next.EffectiveLimit,
+	}
+	if prev.Edges != nil || next.Edges != nil {
+		conn.Edges = append(append([]*Edge[T]{}, prev.Edges...), next.Edges...)
+	}
+	if prev.Nodes != nil || next.Nodes != nil {
+		conn.Nodes = append(append([]T{}, prev.Nodes...), next.Nodes...)
+	}
+
+	if prev.PageInfo != nil && next.PageInfo != nil {
+		pageInfo := &PageInfo{
+			HasPreviousPage: prev.PageInfo.HasPreviousPage,
+			HasNextPage:     next.PageInfo.HasNextPage,
+			StartCursor:     prev.PageInfo.StartCursor,
+			EndCursor:       next.PageInfo.EndCursor,
+			CursorExpiresAt: next.PageInfo.CursorExpiresAt,
+		}
+		if pageInfo.StartCursor == nil {
+			pageInfo.StartCursor = next.PageInfo.StartCursor
+		}
+		if pageInfo.EndCursor == nil {
+			pageInfo.EndCursor = prev.PageInfo.EndCursor
+		}
+		if pageInfo.CursorExpiresAt == nil {
+			pageInfo.CursorExpiresAt = prev.PageInfo.CursorExpiresAt
+		}
+		conn.PageInfo = pageInfo
+	}
+	return conn, nil
+}
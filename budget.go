@@ -0,0 +1,135 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// byteBudgetChunkSize is the row count of the first chunk, the later chunks are sized by the average node size seen so far
+const byteBudgetChunkSize = 10
+
+// WithByteBudget fetches the page in chunks, summing sizeOf of the nodes, and stops before the node that would exceed maxBytes,
+// First/Last still caps the number of rows. Each chunk continues after the cursor of the last included row, so the
+// pagination must have stable cursors, e.g. a keyset adapter with EnsurePrimaryOrderBy. If the budget is hit, HasNextPage
+// (HasPreviousPage for Last) is set and the end (start) cursor points to the last included row.
+// At least one row is kept even if it alone exceeds the budget, otherwise the client could never move on.
+// The total count, facets and distinct values are only computed along with the first chunk.
+// First or Last must be set when it runs, so a middleware resolving the default limit, e.g. EnsureLimits,
+// must be placed before it, otherwise the request is rejected instead of being fetched without a budget.
+func WithByteBudget[T any](maxBytes int, sizeOf func(T) int) PaginationMiddleware[T] {
+	if maxBytes <= 0 {
+		panic("maxBytes must be greater than 0")
+	}
+	if sizeOf == nil {
+		panic("sizeOf must be set")
+	}
+	return func(next Pagination[T]) Pagination[T] {
+		return PaginationFunc[T](func(ctx context.Context, req *PaginateRequest[T]) (*Connection[T], error) {
+			fromEnd := req.Last != nil
+			limit := req.First
+			if fromEnd {
+				limit = req.Last
+			}
+			if limit == nil {
+				return nil, errors.New("first or last must be set for the byte budget, place EnsureLimits before WithByteBudget")
+			}
+			if *limit <= 0 {
+				return next.Paginate(ctx, req)
+			}
+
+			// edges and page info of the chunks are required to continue and to merge them
+			skip := GetSkip(ctx)
+			innerSkip := skip
+			innerSkip.Edges, innerSkip.PageInfo = false, false
+			ctx = WithSkip(ctx, innerSkip)
+
+			chunkReq := *req
+			chunkSize := min(*limit, byteBudgetChunkSize)
+			var conn, last *Connection[T]
+			var edges []*Edge[T]
+			used, exceeded := 0, false
+			for {
+				if fromEnd {
+					chunkReq.Last = &chunkSize
+				} else {
+					chunkReq.First = &chunkSize
+				}
+				chunk, err := next.Paginate(ctx, &chunkReq)
+				if err != nil {
+					return nil, err
+				}
+				if conn == nil {
+					conn = chunk
+					restSkip := innerSkip
+					restSkip.TotalCount, restSkip.Facets, restSkip.DistinctValues = true, true, true
+					ctx = WithSkip(ctx, restSkip)
+				} else {
+					conn.DroppedNodes += chunk.DroppedNodes
+				}
+				last = chunk
+
+				n := len(chunk.Edges)
+				for i := 0; i < n; i++ {
+					edge := chunk.Edges[i]
+					if fromEnd {
+						edge = chunk.Edges[n-1-i]
+					}
+					size := sizeOf(edge.Node)
+					if len(edges) > 0 && used+size > maxBytes {
+						exceeded = true
+						break
+					}
+					used += size
+					edges = append(edges, edge)
+				}
+
+				hasMore := chunk.PageInfo.HasNextPage
+				if fromEnd {
+					hasMore = chunk.PageInfo.HasPreviousPage
+				}
+				if exceeded || len(edges) >= *limit || n < chunkSize || !hasMore {
+					break
+				}
+
+				cursor := edges[len(edges)-1].Cursor
+				if fromEnd {
+					chunkReq.Before = &cursor
+				} else {
+					chunkReq.After = &cursor
+				}
+				avgSize := max(used/len(edges), 1)
+				chunkSize = min(*limit-len(edges), max((maxBytes-used)/avgSize+1, 1))
+			}
+
+			if len(edges) > 0 {
+				if fromEnd {
+					for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+						edges[i], edges[j] = edges[j], edges[i]
+					}
+					conn.PageInfo.HasPreviousPage = exceeded || last.PageInfo.HasPreviousPage
+				} else {
+					conn.PageInfo.HasNextPage = exceeded || last.PageInfo.HasNextPage
+				}
+				startCursor, endCursor := edges[0].Cursor, edges[len(edges)-1].Cursor
+				conn.PageInfo.StartCursor, conn.PageInfo.EndCursor = &startCursor, &endCursor
+				conn.Edges = edges
+				if !skip.Nodes {
+					conn.Nodes = make([]T, len(edges))
+					for i, edge := range edges {
+						conn.Nodes[i] = edge.Node
+					}
+				}
+			}
+			conn.EffectiveLimit = *limit
+
+			if skip.Edges {
+				conn.Edges = nil
+			}
+			if skip.PageInfo {
+				conn.PageInfo = nil
+			}
+			return conn, nil
+		})
+	}
+}
@@ -4,6 +4,7 @@ import "context"
 
 type Skip struct {
 	Edges, Nodes, TotalCount, PageInfo bool
+	Facets, DistinctValues             bool // honored by adapters that compute them, e.g. gormrelay
 }
 
 func (s Skip) All() bool {
@@ -31,3 +32,74 @@ func GetNodeProcessor[T any](ctx context.Context) func(ctx context.Context, node
 	processor, _ := ctx.Value(ctxKeyNodeProcessor{}).(func(ctx context.Context, node T) (T, error))
 	return processor
 }
+
+type ctxKeyEdgeMapper struct{}
+
+// WithEdgeMapper transforms each edge of the page before the connection is assembled, e.g. to wrap the node
+// into a response type or to rewrite the cursor. It runs after the node processors, the nodes and the page info
+// of the connection are taken from the returned edges, and an error aborts the pagination.
+func WithEdgeMapper[T any](ctx context.Context, mapper func(ctx context.Context, edge *Edge[T]) (*Edge[T], error)) context.Context {
+	return context.WithValue(ctx, ctxKeyEdgeMapper{}, mapper)
+}
+
+func GetEdgeMapper[T any](ctx context.Context) func(ctx context.Context, edge *Edge[T]) (*Edge[T], error) {
+	mapper, _ := ctx.Value(ctxKeyEdgeMapper{}).(func(ctx context.Context, edge *Edge[T]) (*Edge[T], error))
+	return mapper
+}
+
+type ctxKeyCursorBuilder struct{}
+
+// WithCursorBuilder makes the keyset adapter compute the cursors of a page in one pass with builder instead of
+// encoding them edge by edge, e.g. to memoize the values shared by many nodes.
+// builder must return one cursor per node in the same order, encoded like cursor.EncodeKeysetCursor,
+// the nodes include the one fetched beyond the limit to detect more pages.
+// It is called once, when the first cursor is needed, with the nodes after the node processor.
+// The cursor middlewares still apply to the returned cursors.
+func WithCursorBuilder[T any](ctx context.Context, builder func(ctx context.Context, nodes []T, orderBys []OrderBy) ([]string, error)) context.Context {
+	return context.WithValue(ctx, ctxKeyCursorBuilder{}, builder)
+}
+
+func GetCursorBuilder[T any](ctx context.Context) func(ctx context.Context, nodes []T, orderBys []OrderBy) ([]string, error) {
+	builder, _ := ctx.Value(ctxKeyCursorBuilder{}).(func(ctx context.Context, nodes []T, orderBys []OrderBy) ([]string, error))
+	return builder
+}
+
+type ctxKeyLenientNodeProcessor struct{}
+
+type lenientNodeProcessor[T any] struct {
+	processor func(ctx context.Context, node T) (T, error)
+	onError   func(ctx context.Context, node T, err error)
+}
+
+// WithLenientNodeProcessor is like WithNodeProcessor, but a node whose processing fails is dropped from the page
+// instead of failing it, onError (optional) is called with the failed node and the error.
+// The page is trimmed to first/last before processing, so a page may contain fewer nodes than requested,
+// the number of dropped nodes is reported as Connection.DroppedNodes and the cursors are those of the retained nodes.
+func WithLenientNodeProcessor[T any](ctx context.Context, processor func(ctx context.Context, node T) (T, error), onError func(ctx context.Context, node T, err error)) context.Context {
+	return context.WithValue(ctx, ctxKeyLenientNodeProcessor{}, &lenientNodeProcessor[T]{
+		processor: processor,
+		onError:   onError,
+	})
+}
+
+func GetLenientNodeProcessor[T any](ctx context.Context) (processor func(ctx context.Context, node T) (T, error), onError func(ctx context.Context, node T, err error)) {
+	p, _ := ctx.Value(ctxKeyLenientNodeProcessor{}).(*lenientNodeProcessor[T])
+	if p == nil {
+		return nil, nil
+	}
+	return p.processor, p.onError
+}
+
+type ctxKeyStableEmptyPageInfo struct{}
+
+// WithStableEmptyPageInfo makes pagination return a PageInfo with all flags false and nil cursors
+// when the total count is zero, the keyset and offset adapters of package cursor skip the find then.
+// If the total count is skipped, it does so when no rows are fetched, e.g. for an after past the end.
+func WithStableEmptyPageInfo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyStableEmptyPageInfo{}, true)
+}
+
+func GetStableEmptyPageInfo(ctx context.Context) bool {
+	stable, _ := ctx.Value(ctxKeyStableEmptyPageInfo{}).(bool)
+	return stable
+}
@@ -0,0 +1,42 @@
+package relay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+type continuation[T, F any] struct {
+	Request *PaginateRequest[T] `json:"request"`
+	Filter  F                   `json:"filter"`
+}
+
+// EncodeContinuation serializes the whole request (cursors, limits and orderBys) and the filter into one opaque token,
+// so that a client can fetch the next page by passing back a single value.
+// e.g. EncodeContinuation(&PaginateRequest[T]{After: conn.PageInfo.EndCursor, First: req.First, OrderBys: req.OrderBys}, filter)
+// The filter must round-trip through encoding/json. The token is only encoded, use cursor.EncodeContinuationGCM if it must be tamper-proof.
+func EncodeContinuation[T, F any](req *PaginateRequest[T], filter F) (string, error) {
+	b, err := json.Marshal(continuation[T, F]{Request: req, Filter: filter})
+	if err != nil {
+		return "", errors.Wrap(err, "marshal continuation")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeContinuation reconstructs the request and the filter encoded by EncodeContinuation
+func DecodeContinuation[T, F any](token string) (*PaginateRequest[T], F, error) {
+	var zero F
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, zero, errors.Wrap(err, "decode continuation")
+	}
+	c := continuation[T, F]{Request: &PaginateRequest[T]{}}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, zero, errors.Wrap(err, "unmarshal continuation")
+	}
+	if c.Request == nil {
+		return nil, zero, errors.New("missing request in continuation")
+	}
+	return c.Request, c.Filter, nil
+}
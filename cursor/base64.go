@@ -33,7 +33,7 @@ func Base64[T any](next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
 		}
 
 		// Encrypt the cursor
-		for _, edge := range rsp.LazyEdges {
+		rsp.EachLazyEdge(func(edge *relay.LazyEdge[T]) {
 			originalCursor := edge.Cursor
 			edge.Cursor = func(ctx context.Context, node T) (string, error) {
 				cursor, err := originalCursor(ctx, node)
@@ -42,7 +42,7 @@ func Base64[T any](next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
 				}
 				return base64.RawURLEncoding.EncodeToString([]byte(cursor)), nil
 			}
-		}
+		})
 
 		return rsp, nil
 	}
@@ -0,0 +1,110 @@
+package cursor
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+)
+
+// compressedPrefix marks compressed cursors, '.' is not in the base64 alphabet, so it never starts a plain Base64 cursor
+const compressedPrefix = "z."
+
+// maxDecompressedCursorSize bounds the decompression of untrusted cursors
+const maxDecompressedCursorSize = 64 << 10
+
+func encodeCompressed(cursor string) (string, error) {
+	plain := base64.RawURLEncoding.EncodeToString([]byte(cursor))
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", errors.Wrap(err, "create flate writer")
+	}
+	if _, err := w.Write([]byte(cursor)); err != nil {
+		return "", errors.Wrap(err, "compress cursor")
+	}
+	if err := w.Close(); err != nil {
+		return "", errors.Wrap(err, "compress cursor")
+	}
+
+	compressed := compressedPrefix + base64.RawURLEncoding.EncodeToString(buf.Bytes())
+	if len(compressed) >= len(plain) {
+		return plain, nil
+	}
+	return compressed, nil
+}
+
+func decodeCompressed(s string) (string, error) {
+	data, ok := strings.CutPrefix(s, compressedPrefix)
+	if !ok {
+		cursor, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		return string(cursor), nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+	r := flate.NewReader(bytes.NewReader(b))
+	defer r.Close()
+	cursor, err := io.ReadAll(io.LimitReader(r, maxDecompressedCursorSize+1))
+	if err != nil {
+		return "", errors.Wrap(err, "decompress cursor")
+	}
+	if len(cursor) > maxDecompressedCursorSize {
+		return "", errors.New("decompressed cursor too large")
+	}
+	return string(cursor), nil
+}
+
+// Compressed is like Base64, but deflates the cursors before encoding them if that makes them shorter,
+// e.g. keyset cursors of several fields. The compressed cursors are prefixed with "z.", so plain Base64 cursors
+// issued before switching to it are still accepted. Wrap it with GCM to compress before encrypting:
+// cursor.GCM[T](gcm)(cursor.Compressed(adapter))
+func Compressed[T any](next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		if req.After != nil {
+			cursor, err := decodeCompressed(*req.After)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid after cursor")
+			}
+			req.After = lo.ToPtr(cursor)
+		}
+
+		if req.Before != nil {
+			cursor, err := decodeCompressed(*req.Before)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid before cursor")
+			}
+			req.Before = lo.ToPtr(cursor)
+		}
+
+		rsp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		rsp.EachLazyEdge(func(edge *relay.LazyEdge[T]) {
+			originalCursor := edge.Cursor
+			edge.Cursor = func(ctx context.Context, node T) (string, error) {
+				cursor, err := originalCursor(ctx, node)
+				if err != nil {
+					return "", err
+				}
+				return encodeCompressed(cursor)
+			}
+		})
+
+		return rsp, nil
+	}
+}
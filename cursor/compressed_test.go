@@ -0,0 +1,88 @@
+package cursor
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+)
+
+func TestCompressed(t *testing.T) {
+	type node struct {
+		ID        int
+		Category  string
+		Title     string
+		Priority  int
+		CreatedAt time.Time
+	}
+	keys := []string{"Category", "Title", "Priority", "CreatedAt", "ID"}
+	nodes := []node{
+		{ID: 1, Category: "documentation", Title: "documentation of the documentation", Priority: 3, CreatedAt: time.Unix(1700000000, 0)},
+		{ID: 2, Category: "documentation", Title: "documentation of the cursors", Priority: 3, CreatedAt: time.Unix(1700000001, 0)},
+	}
+
+	// the adapter emits the keyset cursors as they are and records the cursors it receives
+	var after *string
+	adapter := func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[node], error) {
+		after = req.After
+		return &relay.ApplyCursorsResponse[node]{
+			LazyEdges: lo.Map(nodes, func(n node, _ int) *relay.LazyEdge[node] {
+				return &relay.LazyEdge[node]{
+					Node: n,
+					Cursor: func(ctx context.Context, n node) (string, error) {
+						return EncodeKeysetCursor(n, keys)
+					},
+				}
+			}),
+		}, nil
+	}
+	raw, err := EncodeKeysetCursor(nodes[1], keys)
+	require.NoError(t, err)
+
+	p := relay.New(Compressed(adapter))
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[node]{First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	endCursor := *conn.PageInfo.EndCursor
+	require.True(t, strings.HasPrefix(endCursor, "z."))
+	require.Less(t, len(endCursor), len(base64.RawURLEncoding.EncodeToString([]byte(raw))))
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[node]{After: &endCursor, First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, raw, *after)
+
+	values, err := Inspect(endCursor)
+	require.NoError(t, err)
+	require.Equal(t, "documentation of the cursors", values["Title"])
+
+	// a legacy cursor of Base64 is still accepted
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[node]{After: lo.ToPtr(base64.RawURLEncoding.EncodeToString([]byte(raw))), First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, raw, *after)
+
+	// a short cursor is not compressed
+	short, err := encodeCompressed(EncodeOffsetCursor(1))
+	require.NoError(t, err)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString([]byte(EncodeOffsetCursor(1))), short)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[node]{Before: lo.ToPtr("z.AAAA"), Last: lo.ToPtr(2)})
+	require.ErrorContains(t, err, "invalid before cursor: decompress cursor")
+
+	// compress then encrypt
+	gcm, err := NewGCM([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+	p = relay.New(GCM[node](gcm)(Compressed(adapter)))
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[node]{First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	decrypted, err := decryptGCM(gcm, *conn.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(decrypted, "z."))
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[node]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, raw, *after)
+}
@@ -0,0 +1,27 @@
+package cursor
+
+import (
+	"crypto/cipher"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/relay"
+)
+
+// EncodeContinuationGCM is relay.EncodeContinuation with the token encrypted by GCM(AES)
+func EncodeContinuationGCM[T, F any](gcm cipher.AEAD, req *relay.PaginateRequest[T], filter F) (string, error) {
+	token, err := relay.EncodeContinuation(req, filter)
+	if err != nil {
+		return "", err
+	}
+	return encryptGCM(gcm, token)
+}
+
+// DecodeContinuationGCM decrypts and decodes the token encoded by EncodeContinuationGCM
+func DecodeContinuationGCM[T, F any](gcm cipher.AEAD, token string) (*relay.PaginateRequest[T], F, error) {
+	decryptedToken, err := decryptGCM(gcm, token)
+	if err != nil {
+		var zero F
+		return nil, zero, errors.Wrap(err, "invalid continuation")
+	}
+	return relay.DecodeContinuation[T, F](decryptedToken)
+}
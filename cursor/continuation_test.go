@@ -0,0 +1,74 @@
+package cursor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+)
+
+type continuationFilter struct {
+	Name      *string    `json:"name,omitempty"`
+	AgeIn     []int      `json:"ageIn,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+func TestContinuation(t *testing.T) {
+	req := &relay.PaginateRequest[any]{
+		After: lo.ToPtr(`{"ID":10}`),
+		First: lo.ToPtr(10),
+		OrderBys: []relay.OrderBy{
+			{Field: "Age", Desc: true},
+			{Field: "ID", Desc: false},
+		},
+	}
+	filter := &continuationFilter{
+		Name:      lo.ToPtr("foo"),
+		AgeIn:     []int{18, 20},
+		CreatedAt: lo.ToPtr(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+
+	{
+		token, err := relay.EncodeContinuation(req, filter)
+		require.NoError(t, err)
+
+		decoded, decodedFilter, err := relay.DecodeContinuation[any, *continuationFilter](token)
+		require.NoError(t, err)
+		require.Equal(t, req, decoded)
+		require.Equal(t, filter, decodedFilter)
+
+		// a nil filter round-trips as nil
+		token, err = relay.EncodeContinuation[any, *continuationFilter](req, nil)
+		require.NoError(t, err)
+		decoded, decodedFilter, err = relay.DecodeContinuation[any, *continuationFilter](token)
+		require.NoError(t, err)
+		require.Equal(t, req, decoded)
+		require.Nil(t, decodedFilter)
+
+		_, _, err = relay.DecodeContinuation[any, *continuationFilter]("invalid%20x")
+		require.ErrorContains(t, err, "decode continuation")
+	}
+
+	{
+		gcmKey, err := generateGCMKey(32)
+		require.NoError(t, err)
+
+		gcm, err := NewGCM(gcmKey)
+		require.NoError(t, err)
+
+		token, err := EncodeContinuationGCM(gcm, req, filter)
+		require.NoError(t, err)
+
+		decoded, decodedFilter, err := DecodeContinuationGCM[any, *continuationFilter](gcm, token)
+		require.NoError(t, err)
+		require.Equal(t, req, decoded)
+		require.Equal(t, filter, decodedFilter)
+
+		plainToken, err := relay.EncodeContinuation(req, filter)
+		require.NoError(t, err)
+		_, _, err = DecodeContinuationGCM[any, *continuationFilter](gcm, plainToken)
+		require.ErrorContains(t, err, "invalid continuation")
+	}
+}
@@ -0,0 +1,86 @@
+package cursor
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+)
+
+var ErrCursorExpired = errors.New("cursor expired")
+
+func encodeExpiry(issuedAt time.Time, cursor string) string {
+	return strconv.FormatInt(issuedAt.Unix(), 10) + ":" + cursor
+}
+
+func decodeExpiry(ttl time.Duration, now time.Time, cursor string) (string, error) {
+	issuedAtStr, cursor, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return "", errors.New("missing issue timestamp")
+	}
+	issuedAtUnix, err := strconv.ParseInt(issuedAtStr, 10, 64)
+	if err != nil {
+		return "", errors.Wrap(err, "parse issue timestamp")
+	}
+	if now.Sub(time.Unix(issuedAtUnix, 0)) > ttl {
+		return "", ErrCursorExpired
+	}
+	return cursor, nil
+}
+
+// WithExpiry embeds the issue timestamp into cursors and rejects cursors older than ttl with ErrCursorExpired.
+// The expiry of the issued cursors is reported as PageInfo.CursorExpiresAt.
+// The timestamp is not protected by itself, so wrap it with GCM if the cursors must be tamper-proof:
+// cursor.GCM[T](gcm)(cursor.WithExpiry[T](ttl)(adapter))
+func WithExpiry[T any](ttl time.Duration) relay.CursorMiddleware[T] {
+	if ttl <= 0 {
+		panic("ttl must be positive")
+	}
+	return func(next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+		return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+			now := time.Now()
+
+			if req.After != nil {
+				cursor, err := decodeExpiry(ttl, now, *req.After)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid after cursor")
+				}
+				req.After = lo.ToPtr(cursor)
+			}
+
+			if req.Before != nil {
+				cursor, err := decodeExpiry(ttl, now, *req.Before)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid before cursor")
+				}
+				req.Before = lo.ToPtr(cursor)
+			}
+
+			rsp, err := next(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			// the timestamp is encoded in seconds, so the expiry is based on the truncated issue time
+			expiresAt := time.Unix(now.Unix(), 0).Add(ttl)
+			rsp.CursorExpiresAt = &expiresAt
+
+			rsp.EachLazyEdge(func(edge *relay.LazyEdge[T]) {
+				originalCursor := edge.Cursor
+				edge.Cursor = func(ctx context.Context, node T) (string, error) {
+					cursor, err := originalCursor(ctx, node)
+					if err != nil {
+						return "", err
+					}
+					return encodeExpiry(now, cursor), nil
+				}
+			})
+
+			return rsp, nil
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cursor
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+)
+
+func TestWithExpiry(t *testing.T) {
+	var received *relay.ApplyCursorsRequest
+	applyCursorsFunc := WithExpiry[int](time.Hour)(func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[int], error) {
+		received = req
+		return &relay.ApplyCursorsResponse[int]{
+			LazyEdges: []*relay.LazyEdge[int]{
+				{
+					Node: 1,
+					Cursor: func(ctx context.Context, node int) (string, error) {
+						return EncodeOffsetCursor(node), nil
+					},
+				},
+			},
+		}, nil
+	})
+
+	before := time.Now()
+	rsp, err := applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{Limit: 1})
+	require.NoError(t, err)
+	require.NotNil(t, rsp.CursorExpiresAt)
+	require.WithinRange(t, *rsp.CursorExpiresAt, before.Add(time.Hour-time.Second), time.Now().Add(time.Hour))
+	cursor, err := rsp.LazyEdges[0].Cursor(context.Background(), rsp.LazyEdges[0].Node)
+	require.NoError(t, err)
+	require.Regexp(t, `^\d+:1$`, cursor)
+
+	_, err = applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(cursor), Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, lo.ToPtr("1"), received.After)
+
+	expired := strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10) + ":1"
+	_, err = applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(expired), Limit: 1})
+	require.ErrorContains(t, err, "invalid after cursor: cursor expired")
+	require.True(t, errors.Is(err, ErrCursorExpired))
+
+	_, err = applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{Before: lo.ToPtr("1"), Limit: 1})
+	require.ErrorContains(t, err, "invalid before cursor: missing issue timestamp")
+}
+
+func TestWithExpiryPageInfo(t *testing.T) {
+	p := relay.New(WithExpiry[int](time.Minute)(func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[int], error) {
+		return &relay.ApplyCursorsResponse[int]{
+			LazyEdges: []*relay.LazyEdge[int]{
+				{
+					Node: 1,
+					Cursor: func(ctx context.Context, node int) (string, error) {
+						return EncodeOffsetCursor(node), nil
+					},
+				},
+			},
+		}, nil
+	}))
+
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[int]{First: lo.ToPtr(1)})
+	require.NoError(t, err)
+	require.NotNil(t, conn.PageInfo.CursorExpiresAt)
+	issuedAt, _, _ := strings.Cut(*conn.PageInfo.EndCursor, ":")
+	require.Equal(t, issuedAt, strconv.FormatInt(conn.PageInfo.CursorExpiresAt.Add(-time.Minute).Unix(), 10))
+}
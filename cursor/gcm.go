@@ -82,7 +82,7 @@ func GCM[T any](gcm cipher.AEAD) relay.CursorMiddleware[T] {
 				return nil, err
 			}
 
-			for _, edge := range rsp.LazyEdges {
+			rsp.EachLazyEdge(func(edge *relay.LazyEdge[T]) {
 				originalCursor := edge.Cursor
 				edge.Cursor = func(ctx context.Context, node T) (string, error) {
 					cursor, err := originalCursor(ctx, node)
@@ -95,7 +95,7 @@ func GCM[T any](gcm cipher.AEAD) relay.CursorMiddleware[T] {
 					}
 					return encryptedCursor, nil
 				}
-			}
+			})
 
 			return rsp, nil
 		}
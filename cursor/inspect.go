@@ -0,0 +1,32 @@
+package cursor
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrCursorEncrypted = errors.New("cursor is encrypted or not recognized")
+
+// Inspect decodes a cursor emitted with Base64 or Compressed for observability, it returns {"offset": n} for an offset cursor in either format
+// and the keyset values for a keyset cursor. The values are decoded without type information, e.g. times are unix nanos.
+// Cursors that are not plain offset or keyset cursors, e.g. from GCM, result in ErrCursorEncrypted.
+func Inspect(s string) (map[string]any, error) {
+	c, err := decodeCompressed(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode cursor")
+	}
+
+	if offset, err := DecodeOffsetCursor(c); err == nil {
+		return map[string]any{"offset": offset}, nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(c))
+	dec.UseNumber()
+	var keyset map[string]any
+	if err := dec.Decode(&keyset); err != nil || keyset == nil || dec.More() {
+		return nil, errors.WithStack(ErrCursorEncrypted)
+	}
+	return keyset, nil
+}
@@ -0,0 +1,43 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	values, err := Inspect(base64.RawURLEncoding.EncodeToString([]byte(EncodeOffsetCursor(20))))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"offset": 20}, values)
+
+	type node struct {
+		ID        int
+		Name      string
+		CreatedAt time.Time
+	}
+	createdAt := time.Unix(1700000000, 0)
+	cursor, err := EncodeKeysetCursor(node{ID: 5, Name: "foo", CreatedAt: createdAt}, []string{"ID", "Name", "CreatedAt"})
+	require.NoError(t, err)
+	values, err = Inspect(base64.RawURLEncoding.EncodeToString([]byte(cursor)))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"ID":        json.Number("5"),
+		"Name":      "foo",
+		"CreatedAt": json.Number("1700000000000000000"),
+	}, values)
+
+	gcm, err := NewGCM([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+	encrypted, err := encryptGCM(gcm, cursor)
+	require.NoError(t, err)
+	_, err = Inspect(encrypted)
+	require.True(t, errors.Is(err, ErrCursorEncrypted))
+
+	_, err = Inspect("!")
+	require.ErrorContains(t, err, "decode cursor")
+}
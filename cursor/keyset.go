@@ -2,6 +2,8 @@ package cursor
 
 import (
 	"context"
+	"reflect"
+	"sync"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
@@ -14,13 +16,20 @@ type KeysetFinder[T any] interface {
 	Count(ctx context.Context) (int, error)
 }
 
+// KeysetStreamFinder is optionally implemented by a KeysetFinder to stream the nodes of relay.PaginateStream
+// instead of loading them into a slice. The query runs when the returned seq is ranged over, and a nil seq
+// means the request cannot be streamed, so Find is used instead.
+type KeysetStreamFinder[T any] interface {
+	FindStream(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int) (relay.Seq2[T, error], error)
+}
+
 func NewKeysetAdapter[T any](finder KeysetFinder[T]) relay.ApplyCursorsFunc[T] {
 	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
 		keys := lo.Map(req.OrderBys, func(item relay.OrderBy, _ int) string {
 			return item.Field
 		})
 		if len(keys) == 0 {
-			return nil, errors.New("no keys to encode cursor, orderBys must be set for keyset")
+			return nil, errors.WithStack(relay.ErrMissingOrderBy)
 		}
 
 		after, before, err := decodeKeysetCursors[T](req.After, req.Before, keys)
@@ -39,7 +48,7 @@ func NewKeysetAdapter[T any](finder KeysetFinder[T]) relay.ApplyCursorsFunc[T] {
 			totalCount = &count
 		}
 
-		if skip.Edges && skip.Nodes && skip.PageInfo {
+		if (skip.Edges && skip.Nodes && skip.PageInfo) || isStableEmpty(ctx, totalCount) {
 			return &relay.ApplyCursorsResponse[T]{
 				TotalCount: totalCount,
 			}, nil
@@ -49,9 +58,29 @@ func NewKeysetAdapter[T any](finder KeysetFinder[T]) relay.ApplyCursorsFunc[T] {
 			return EncodeKeysetCursor(node, keys)
 		}
 
+		rsp := &relay.ApplyCursorsResponse[T]{
+			TotalCount: totalCount,
+			// It would be very costly to check whether after and before really exist,
+			// So it is usually not worth it. Normally, checking that it is not nil is sufficient.
+			HasAfterOrPrevious: after != nil,
+			HasBeforeOrNext:    before != nil,
+		}
+
 		var edges []*relay.LazyEdge[T]
 		if req.Limit <= 0 || (totalCount != nil && *totalCount <= 0) {
 			edges = make([]*relay.LazyEdge[T], 0)
+		} else if seq, err := findStream(ctx, finder, req, after, before); err != nil {
+			return nil, err
+		} else if seq != nil {
+			rsp.LazyEdgeSeq = func(yield func(*relay.LazyEdge[T], error) bool) {
+				seq(func(node T, err error) bool {
+					if err != nil {
+						return yield(nil, err)
+					}
+					return yield(&relay.LazyEdge[T]{Node: node, Cursor: cursorEncoder}, nil)
+				})
+			}
+			return rsp, nil
 		} else {
 			nodes, err := finder.Find(ctx, after, before, req.OrderBys, req.Limit, req.FromEnd)
 			if err != nil {
@@ -64,16 +93,49 @@ func NewKeysetAdapter[T any](finder KeysetFinder[T]) relay.ApplyCursorsFunc[T] {
 					Cursor: cursorEncoder,
 				}
 			}
+			if builder := relay.GetCursorBuilder[T](ctx); builder != nil {
+				buildCursors(edges, req.OrderBys, builder)
+			}
 		}
 
-		return &relay.ApplyCursorsResponse[T]{
-			LazyEdges:  edges,
-			TotalCount: totalCount,
-			// It would be very costly to check whether after and before really exist,
-			// So it is usually not worth it. Normally, checking that it is not nil is sufficient.
-			HasAfterOrPrevious: after != nil,
-			HasBeforeOrNext:    before != nil,
-		}, nil
+		rsp.LazyEdges = edges
+		return rsp, nil
+	}
+}
+
+// findStream streams the nodes if the request asks for it and the finder supports it, otherwise it returns nil.
+// The cursor builder needs all the nodes at once, so it disables streaming.
+func findStream[T any](ctx context.Context, finder KeysetFinder[T], req *relay.ApplyCursorsRequest, after, before *map[string]any) (relay.Seq2[T, error], error) {
+	if !req.Stream || req.FromEnd || relay.GetCursorBuilder[T](ctx) != nil {
+		return nil, nil
+	}
+	streamFinder, ok := finder.(KeysetStreamFinder[T])
+	if !ok {
+		return nil, nil
+	}
+	return streamFinder.FindStream(ctx, after, before, req.OrderBys, req.Limit)
+}
+
+// buildCursors makes the edges share the cursors built by builder in one pass, on the first request of a cursor
+func buildCursors[T any](edges []*relay.LazyEdge[T], orderBys []relay.OrderBy, builder func(ctx context.Context, nodes []T, orderBys []relay.OrderBy) ([]string, error)) {
+	var once sync.Once
+	var cursors []string
+	var err error
+	for i, edge := range edges {
+		i := i
+		edge.Cursor = func(ctx context.Context, _ T) (string, error) {
+			once.Do(func() {
+				nodes := lo.Map(edges, func(edge *relay.LazyEdge[T], _ int) T { return edge.Node })
+				cursors, err = builder(ctx, nodes, orderBys)
+				if err == nil && len(cursors) != len(nodes) {
+					err = errors.Errorf("cursor builder returned %d cursors for %d nodes", len(cursors), len(nodes))
+				}
+			})
+			if err != nil {
+				return "", err
+			}
+			return cursors[i], nil
+		}
 	}
 }
 
@@ -87,13 +149,15 @@ var jsoniterForKeyset = jsoniter.Config{
 	TagKey:                 KeysetTagKey,
 }.Froze()
 
+// EncodeKeysetCursor encodes the values of keys of the node into a json object,
+// time values are encoded as unix nanos if T is a struct or struct pointer, other values keep their json representation.
 func EncodeKeysetCursor[T any](node T, keys []string) (string, error) {
 	b, err := jsoniterForKeyset.Marshal(node)
 	if err != nil {
 		return "", errors.Wrap(err, "marshal cursor")
 	}
 
-	m := make(map[string]any)
+	m := make(map[string]jsoniter.RawMessage)
 	if err := jsoniterForKeyset.Unmarshal(b, &m); err != nil {
 		return "", errors.Wrap(err, "unmarshal cursor")
 	}
@@ -106,10 +170,18 @@ func EncodeKeysetCursor[T any](node T, keys []string) (string, error) {
 			return "", errors.Errorf("key %q not found in node", k)
 		}
 	}
-	for k := range m {
+	// the static type, as DecodeKeysetCursor[T] has no node to get the dynamic type from
+	codecs := keysetCodecs(reflect.TypeOf((*T)(nil)).Elem())
+	for k, raw := range m {
 		if _, ok := keysMap[k]; !ok {
 			delete(m, k)
+			continue
 		}
+		v, err := encodeKeysetValue(codecs[k], raw)
+		if err != nil {
+			return "", errors.Wrapf(err, "encode key %q", k)
+		}
+		m[k] = v
 	}
 
 	b, err = jsoniterForKeyset.Marshal(m)
@@ -119,20 +191,42 @@ func EncodeKeysetCursor[T any](node T, keys []string) (string, error) {
 	return string(b), nil
 }
 
+// KeysetCursorForNode builds the keyset cursor of the node for orderBys, e.g. to deep link to the page containing it.
+// The cursor is the raw one, so it must be encoded the same way as the cursor middlewares of the pagination do, e.g. Base64.
+func KeysetCursorForNode[T any](node T, orderBys []relay.OrderBy) (string, error) {
+	keys := lo.Map(orderBys, func(item relay.OrderBy, _ int) string {
+		return item.Field
+	})
+	if len(keys) == 0 {
+		return "", errors.WithStack(relay.ErrMissingOrderBy)
+	}
+	return EncodeKeysetCursor(node, keys)
+}
+
+// DecodeKeysetCursor decodes the cursor encoded by EncodeKeysetCursor,
+// the values are decoded according to the field types of T if T is a struct or struct pointer.
 func DecodeKeysetCursor[T any](cursor string, keys []string) (map[string]any, error) {
-	var m map[string]any
-	if err := jsoniterForKeyset.Unmarshal([]byte(cursor), &m); err != nil {
+	var raws map[string]jsoniter.RawMessage
+	if err := jsoniterForKeyset.Unmarshal([]byte(cursor), &raws); err != nil {
 		return nil, errors.Wrap(err, "unmarshal cursor")
 	}
 
-	if len(m) != len(keys) {
-		return nil, errors.Errorf("cursor has %d keys, but %d keys are expected", len(m), len(keys))
+	if len(raws) != len(keys) {
+		return nil, errors.Errorf("cursor has %d keys, but %d keys are expected", len(raws), len(keys))
 	}
 
+	codecs := keysetCodecs(reflect.TypeOf((*T)(nil)).Elem())
+	m := make(map[string]any, len(raws))
 	for _, k := range keys {
-		if _, ok := m[k]; !ok {
+		raw, ok := raws[k]
+		if !ok {
 			return nil, errors.Errorf("key %q not found in cursor", k)
 		}
+		v, err := decodeKeysetValue(codecs[k], raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode key %q", k)
+		}
+		m[k] = v
 	}
 	return m, nil
 }
@@ -0,0 +1,167 @@
+package cursor
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// keysetCodec is the encoding of a keyset value within the cursor payload
+type keysetCodec int
+
+const (
+	keysetCodecJSON  keysetCodec = iota // generic json, decoded as any
+	keysetCodecInt                      // decimal, decoded as int64
+	keysetCodecUint                     // decimal, decoded as uint64
+	keysetCodecFloat                    // decoded as float64
+	keysetCodecTime                     // unix nanos, decoded as time.Time in UTC
+	keysetCodecBytes                    // base64, decoded as []byte
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func keysetCodecOf(typ reflect.Type) keysetCodec {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == timeType {
+		return keysetCodecTime
+	}
+	// types with custom marshaling keep their own json representation
+	for _, t := range []reflect.Type{typ, reflect.PointerTo(typ)} {
+		if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+			return keysetCodecJSON
+		}
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return keysetCodecInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return keysetCodecUint
+	case reflect.Float32, reflect.Float64:
+		return keysetCodecFloat
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return keysetCodecBytes
+		}
+	}
+	return keysetCodecJSON
+}
+
+// keysetCodecs returns the codecs of the keys of a struct (or struct pointer) type,
+// the keys are named the same way as jsoniterForKeyset does. It returns nil for other types.
+func keysetCodecs(typ reflect.Type) map[string]keysetCodec {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	codecs := make(map[string]keysetCodec)
+	collectKeysetCodecs(typ, codecs)
+	return codecs
+}
+
+func collectKeysetCodecs(typ reflect.Type, codecs map[string]keysetCodec) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get(KeysetTagKey)
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			collectKeysetCodecs(fieldType, codecs)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := codecs[name]; ok {
+			continue
+		}
+		codecs[name] = keysetCodecOf(field.Type)
+	}
+}
+
+// jsoniter unmarshals null into an empty RawMessage
+func isNullKeysetValue(raw jsoniter.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// encodeKeysetValue converts the json representation of the value to the one of the codec
+func encodeKeysetValue(codec keysetCodec, raw jsoniter.RawMessage) (jsoniter.RawMessage, error) {
+	if isNullKeysetValue(raw) {
+		return jsoniter.RawMessage("null"), nil
+	}
+	if codec != keysetCodecTime {
+		return raw, nil
+	}
+
+	var t time.Time
+	if err := jsoniterForKeyset.Unmarshal(raw, &t); err != nil {
+		return nil, errors.Wrap(err, "unmarshal time")
+	}
+	// times that overflow unix nanos keep the RFC3339 representation
+	if t.Before(time.Unix(0, math.MinInt64)) || t.After(time.Unix(0, math.MaxInt64)) {
+		return raw, nil
+	}
+	return jsoniterForKeyset.Marshal(t.UnixNano())
+}
+
+func decodeKeysetValue(codec keysetCodec, raw jsoniter.RawMessage) (any, error) {
+	if isNullKeysetValue(raw) {
+		return nil, nil
+	}
+
+	var err error
+	switch codec {
+	case keysetCodecInt:
+		var v int64
+		err = jsoniterForKeyset.Unmarshal(raw, &v)
+		return v, err
+	case keysetCodecUint:
+		var v uint64
+		err = jsoniterForKeyset.Unmarshal(raw, &v)
+		return v, err
+	case keysetCodecFloat:
+		var v float64
+		err = jsoniterForKeyset.Unmarshal(raw, &v)
+		return v, err
+	case keysetCodecTime:
+		var nanos int64
+		if jsoniterForKeyset.Unmarshal(raw, &nanos) == nil {
+			return time.Unix(0, nanos).UTC(), nil
+		}
+		var v time.Time
+		err = jsoniterForKeyset.Unmarshal(raw, &v)
+		return v, err
+	case keysetCodecBytes:
+		var v []byte
+		err = jsoniterForKeyset.Unmarshal(raw, &v)
+		return v, err
+	default:
+		var v any
+		err = jsoniterForKeyset.Unmarshal(raw, &v)
+		return v, err
+	}
+}
@@ -1,10 +1,13 @@
 package cursor
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
 	"gorm.io/gorm"
 )
 
@@ -85,3 +88,89 @@ func TestEncodeKeysetCursor(t *testing.T) {
 	require.ErrorContains(t, err, `func() is unsupported type`)
 	require.Empty(t, cursor)
 }
+
+func TestKeysetCursorForNode(t *testing.T) {
+	type User struct {
+		ID        int
+		Name      string
+		CreatedAt time.Time
+	}
+	user := &User{ID: 5, Name: "molon", CreatedAt: time.Unix(1700000000, 0)}
+
+	cursor, err := KeysetCursorForNode(user, []relay.OrderBy{
+		{Field: "CreatedAt", Desc: true},
+		{Field: "ID", Desc: false},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"CreatedAt":1700000000000000000,"ID":5}`, cursor)
+
+	keyset, err := DecodeKeysetCursor[*User](cursor, []string{"CreatedAt", "ID"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"CreatedAt": user.CreatedAt.UTC(), "ID": int64(5)}, keyset)
+
+	_, err = KeysetCursorForNode(user, nil)
+	require.ErrorIs(t, err, relay.ErrMissingOrderBy)
+}
+
+func TestKeysetCursorTypedCodecs(t *testing.T) {
+	type Node struct {
+		ID        uint64
+		Rank      int `relay:"rank"`
+		Score     float64
+		Raw       []byte
+		CreatedAt time.Time
+		DeletedAt *time.Time
+		Zero      time.Time
+		Name      string
+	}
+	node := &Node{
+		ID:        math.MaxUint64,
+		Rank:      -3,
+		Score:     1.5,
+		Raw:       []byte("raw"),
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("", 8*3600)),
+		Name:      "molon",
+	}
+	keys := []string{"ID", "rank", "Score", "Raw", "CreatedAt", "DeletedAt", "Zero", "Name"}
+
+	cursor, err := EncodeKeysetCursor(node, keys)
+	require.NoError(t, err)
+	require.Equal(t, `{"CreatedAt":1704135845000000006,"DeletedAt":null,"ID":18446744073709551615,"Name":"molon","Raw":"cmF3","Score":1.5,"Zero":"0001-01-01T00:00:00Z","rank":-3}`, cursor)
+
+	m, err := DecodeKeysetCursor[*Node](cursor, keys)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"ID":        uint64(math.MaxUint64),
+		"rank":      int64(-3),
+		"Score":     1.5,
+		"Raw":       []byte("raw"),
+		"CreatedAt": node.CreatedAt.UTC(),
+		"DeletedAt": nil,
+		"Zero":      time.Time{},
+		"Name":      "molon",
+	}, m)
+
+	// times encoded as RFC3339 are still accepted
+	m, err = DecodeKeysetCursor[*Node](`{"CreatedAt":"2024-01-02T03:04:05Z"}`, []string{"CreatedAt"})
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), m["CreatedAt"])
+
+	// without type information, values are decoded as generic json
+	m, err = DecodeKeysetCursor[any](`{"ID":1}`, []string{"ID"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"ID": float64(1)}, m)
+
+	_, err = DecodeKeysetCursor[*Node](`{"ID":"x"}`, []string{"ID"})
+	require.ErrorContains(t, err, `decode key "ID"`)
+
+	// interface typed nodes keep the json representation on both sides, whatever the dynamic type is
+	for _, node := range []any{node, map[string]any{"CreatedAt": node.CreatedAt}} {
+		cursor, err := EncodeKeysetCursor(node, []string{"CreatedAt"})
+		require.NoError(t, err)
+		require.Equal(t, `{"CreatedAt":"2024-01-02T03:04:05.000000006+08:00"}`, cursor)
+
+		m, err := DecodeKeysetCursor[any](cursor, []string{"CreatedAt"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"CreatedAt": "2024-01-02T03:04:05.000000006+08:00"}, m)
+	}
+}
@@ -3,6 +3,7 @@ package cursor
 import (
 	"context"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/theplant/relay"
@@ -44,7 +45,7 @@ func NewOffsetAdapter[T any](finder OffsetFinder[T]) relay.ApplyCursorsFunc[T] {
 			totalCount = &count
 		}
 
-		if skipFind {
+		if skipFind || isStableEmpty(ctx, totalCount) {
 			return &relay.ApplyCursorsResponse[T]{
 				TotalCount: totalCount,
 			}, nil
@@ -118,12 +119,25 @@ func NewOffsetAdapter[T any](finder OffsetFinder[T]) relay.ApplyCursorsFunc[T] {
 	}
 }
 
+// isStableEmpty reports whether there is nothing to find and relay.Paginate builds the stable empty PageInfo
+func isStableEmpty(ctx context.Context, totalCount *int) bool {
+	return totalCount != nil && *totalCount == 0 && relay.GetStableEmptyPageInfo(ctx)
+}
+
 func EncodeOffsetCursor(offset int) string {
 	return strconv.Itoa(offset)
 }
 
+const readableOffsetPrefix = "offset:"
+
+// EncodeReadableOffsetCursor encodes the offset as "offset:N", see WithReadableOffset
+func EncodeReadableOffsetCursor(offset int) string {
+	return readableOffsetPrefix + strconv.Itoa(offset)
+}
+
+// DecodeOffsetCursor decodes both the plain "N" and the readable "offset:N" formats
 func DecodeOffsetCursor(cursor string) (int, error) {
-	offset, err := strconv.Atoi(cursor)
+	offset, err := strconv.Atoi(strings.TrimPrefix(cursor, readableOffsetPrefix))
 	if err != nil {
 		return 0, errors.Wrapf(err, "decode offset cursor %q", cursor)
 	}
@@ -156,3 +170,34 @@ func decodeOffsetCursors(after, before *string) (afterOffset, beforeOffset *int,
 	}
 	return afterOffset, beforeOffset, nil
 }
+
+// WithReadableOffset emits offset cursors as "offset:N" instead of "N", e.g. for internal tools.
+// It must wrap the offset adapter directly, codecs can be applied on top: cursor.Base64[T](cursor.WithReadableOffset[T]()(adapter))
+// Both formats are accepted as after and before, so the cursors issued before enabling it keep working.
+func WithReadableOffset[T any]() relay.CursorMiddleware[T] {
+	return func(next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+		return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+			rsp, err := next(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			rsp.EachLazyEdge(func(edge *relay.LazyEdge[T]) {
+				originalCursor := edge.Cursor
+				edge.Cursor = func(ctx context.Context, node T) (string, error) {
+					cursor, err := originalCursor(ctx, node)
+					if err != nil {
+						return "", err
+					}
+					offset, err := DecodeOffsetCursor(cursor)
+					if err != nil {
+						return "", err
+					}
+					return EncodeReadableOffsetCursor(offset), nil
+				}
+			})
+
+			return rsp, nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cursor
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+)
+
+type intsFinder []int
+
+func (f intsFinder) Find(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]int, error) {
+	return f[skip:min(skip+limit, len(f))], nil
+}
+
+func (f intsFinder) Count(ctx context.Context) (int, error) {
+	return len(f), nil
+}
+
+func TestWithReadableOffset(t *testing.T) {
+	p := relay.New(WithReadableOffset[int]()(NewOffsetAdapter[int](intsFinder{1, 2, 3, 4, 5})))
+
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[int]{First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, conn.Nodes)
+	require.Equal(t, lo.ToPtr("offset:0"), conn.PageInfo.StartCursor)
+	require.Equal(t, lo.ToPtr("offset:1"), conn.PageInfo.EndCursor)
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4}, conn.Nodes)
+	require.Equal(t, lo.ToPtr("offset:3"), conn.PageInfo.EndCursor)
+
+	// the plain format is still accepted
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{After: lo.ToPtr(EncodeOffsetCursor(3)), First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, []int{5}, conn.Nodes)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{After: lo.ToPtr("offset:x"), First: lo.ToPtr(2)})
+	require.ErrorContains(t, err, `decode offset cursor "offset:x"`)
+
+	p = relay.New(Base64(WithReadableOffset[int]()(NewOffsetAdapter[int](intsFinder{1, 2, 3}))))
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{First: lo.ToPtr(1)})
+	require.NoError(t, err)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString([]byte("offset:0")), *conn.PageInfo.EndCursor)
+	values, err := Inspect(*conn.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"offset": 0}, values)
+}
@@ -0,0 +1,87 @@
+package cursor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+)
+
+// ErrInvalidCursor is returned (wrapped) when a cursor is well-formed but cannot be used for the request,
+// e.g. it was issued for a different order by
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// orderSignature is a short digest of the orderBys, it only has to tell different orders apart, not to be tamper-proof
+func orderSignature(orderBys []relay.OrderBy) (string, error) {
+	b, err := json.Marshal(orderBys)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal orderBys")
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:6]), nil
+}
+
+func verifyOrderSignature(signature string, cursor string) (string, error) {
+	sig, cursor, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return "", errors.Wrap(ErrInvalidCursor, "missing order signature")
+	}
+	if sig != signature {
+		return "", errors.Wrap(ErrInvalidCursor, "order by changed")
+	}
+	return cursor, nil
+}
+
+// WithOrderSignature embeds a signature of the request orderBys into cursors and rejects cursors issued for
+// a different order with ErrInvalidCursor. It matters most for offset cursors, which are just a position
+// and would otherwise silently page into a differently sorted result set.
+// Wrap it with GCM or Signed if the cursors must be tamper-proof.
+func WithOrderSignature[T any]() relay.CursorMiddleware[T] {
+	return func(next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+		return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+			signature, err := orderSignature(req.OrderBys)
+			if err != nil {
+				return nil, err
+			}
+
+			if req.After != nil {
+				cursor, err := verifyOrderSignature(signature, *req.After)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid after cursor")
+				}
+				req.After = lo.ToPtr(cursor)
+			}
+
+			if req.Before != nil {
+				cursor, err := verifyOrderSignature(signature, *req.Before)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid before cursor")
+				}
+				req.Before = lo.ToPtr(cursor)
+			}
+
+			rsp, err := next(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			rsp.EachLazyEdge(func(edge *relay.LazyEdge[T]) {
+				originalCursor := edge.Cursor
+				edge.Cursor = func(ctx context.Context, node T) (string, error) {
+					cursor, err := originalCursor(ctx, node)
+					if err != nil {
+						return "", err
+					}
+					return signature + ":" + cursor, nil
+				}
+			})
+
+			return rsp, nil
+		}
+	}
+}
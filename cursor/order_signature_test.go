@@ -0,0 +1,34 @@
+package cursor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+)
+
+func TestWithOrderSignature(t *testing.T) {
+	p := relay.New(WithOrderSignature[int]()(NewOffsetAdapter[int](intsFinder{1, 2, 3, 4, 5})))
+	byID := []relay.OrderBy{{Field: "ID"}}
+
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[int]{First: lo.ToPtr(2), OrderBys: byID})
+	require.NoError(t, err)
+	require.Regexp(t, `^[\w-]{8}:1$`, *conn.PageInfo.EndCursor)
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(2), OrderBys: byID})
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4}, conn.Nodes)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(2), OrderBys: []relay.OrderBy{{Field: "ID", Desc: true}}})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+	require.ErrorContains(t, err, "invalid after cursor: order by changed")
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{Before: conn.PageInfo.EndCursor, Last: lo.ToPtr(2)})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[int]{Before: lo.ToPtr(EncodeOffsetCursor(3)), Last: lo.ToPtr(2), OrderBys: byID})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+	require.ErrorContains(t, err, "invalid before cursor: missing order signature")
+}
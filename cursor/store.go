@@ -0,0 +1,66 @@
+package cursor
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+)
+
+// CursorStore keeps cursor payloads server-side and hands out short references to them
+type CursorStore interface {
+	// Put stores the payload and returns the reference to it
+	Put(ctx context.Context, payload string) (string, error)
+	// Get returns the payload of the reference, an unknown or evicted reference must result in an error
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// WithStore replaces the cursors with references from store and resolves the references back to the cursors on the next request.
+// The payloads are stored lazily, i.e. only for the cursors that are actually emitted.
+func WithStore[T any](store CursorStore) relay.CursorMiddleware[T] {
+	if store == nil {
+		panic("store must be set")
+	}
+	return func(next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+		return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+			if req.After != nil {
+				cursor, err := store.Get(ctx, *req.After)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid after cursor")
+				}
+				req.After = lo.ToPtr(cursor)
+			}
+
+			if req.Before != nil {
+				cursor, err := store.Get(ctx, *req.Before)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid before cursor")
+				}
+				req.Before = lo.ToPtr(cursor)
+			}
+
+			rsp, err := next(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			rsp.EachLazyEdge(func(edge *relay.LazyEdge[T]) {
+				originalCursor := edge.Cursor
+				edge.Cursor = func(ctx context.Context, node T) (string, error) {
+					cursor, err := originalCursor(ctx, node)
+					if err != nil {
+						return "", err
+					}
+					ref, err := store.Put(ctx, cursor)
+					if err != nil {
+						return "", errors.Wrap(err, "put cursor")
+					}
+					return ref, nil
+				}
+			})
+
+			return rsp, nil
+		}
+	}
+}
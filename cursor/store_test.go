@@ -0,0 +1,61 @@
+package cursor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+)
+
+type mapStore map[string]string
+
+func (s mapStore) Put(ctx context.Context, payload string) (string, error) {
+	ref := strconv.Itoa(len(s))
+	s[ref] = payload
+	return ref, nil
+}
+
+func (s mapStore) Get(ctx context.Context, ref string) (string, error) {
+	payload, ok := s[ref]
+	if !ok {
+		return "", errors.New("cursor not found")
+	}
+	return payload, nil
+}
+
+func TestWithStore(t *testing.T) {
+	store := mapStore{}
+	var received *relay.ApplyCursorsRequest
+	applyCursorsFunc := WithStore[int](store)(func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[int], error) {
+		received = req
+		return &relay.ApplyCursorsResponse[int]{
+			LazyEdges: []*relay.LazyEdge[int]{
+				{
+					Node: 100,
+					Cursor: func(ctx context.Context, node int) (string, error) {
+						return EncodeOffsetCursor(node), nil
+					},
+				},
+			},
+		}, nil
+	})
+
+	rsp, err := applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{Limit: 1})
+	require.NoError(t, err)
+	require.Empty(t, store)
+	ref, err := rsp.LazyEdges[0].Cursor(context.Background(), rsp.LazyEdges[0].Node)
+	require.NoError(t, err)
+	require.Equal(t, "0", ref)
+	require.Equal(t, mapStore{"0": "100"}, store)
+
+	_, err = applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(ref), Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, lo.ToPtr("100"), received.After)
+
+	_, err = applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{Before: lo.ToPtr("1"), Limit: 1})
+	require.ErrorContains(t, err, "invalid before cursor: cursor not found")
+}
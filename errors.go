@@ -0,0 +1,9 @@
+package relay
+
+import "github.com/pkg/errors"
+
+// ErrQueryTimeout is returned (wrapped) by adapters when the database aborts a query that exceeds its time limit
+var ErrQueryTimeout = errors.New("query timeout")
+
+// ErrMissingOrderBy is returned by keyset adapters when the request has no orderBys to build the keyset from
+var ErrMissingOrderBy = errors.New("no keys to encode cursor, orderBys must be set for keyset")
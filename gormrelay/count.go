@@ -0,0 +1,197 @@
+package gormrelay
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/relay"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type ctxKeyTotalCount struct{}
+
+// prepareTotalCount counts the rows before the adapter runs if an option changes how they are counted,
+// an exact result is passed to Count via the returned context. An estimate is returned instead and the adapter skips
+// the total count, so that the estimate is only reported and never decides which rows are fetched.
+// Last without before is still counted exactly, since the offset adapter locates the last page by the count.
+func prepareTotalCount[T any](ctx context.Context, db *gorm.DB, o *options, req *relay.ApplyCursorsRequest) (_ context.Context, estimate *int, _ error) {
+	if o.totalCountFunc != nil {
+		count, exact, err := o.totalCountFunc(ctx)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "total count")
+		}
+		if exact {
+			return context.WithValue(ctx, ctxKeyTotalCount{}, count), nil, nil
+		}
+		return skipEstimatedCount(ctx, req, count)
+	}
+
+	if o.approximateCount {
+		count, err := approximateCount[T](ctx, db, o)
+		if err != nil {
+			return nil, nil, err
+		}
+		if count != nil {
+			return skipEstimatedCount(ctx, req, *count)
+		}
+	}
+
+	if o.countTimeout > 0 {
+		count, err := countWithTimeout[T](ctx, db, o)
+		if err != nil {
+			return nil, nil, err
+		}
+		if count == nil {
+			skip := relay.GetSkip(ctx)
+			skip.TotalCount = true
+			return relay.WithSkip(ctx, skip), nil, nil
+		}
+		return context.WithValue(ctx, ctxKeyTotalCount{}, *count), nil, nil
+	}
+	return ctx, nil, nil
+}
+
+// skipEstimatedCount makes the adapter skip the total count unless it needs the exact one, see prepareTotalCount
+func skipEstimatedCount(ctx context.Context, req *relay.ApplyCursorsRequest, estimate int) (context.Context, *int, error) {
+	if req.FromEnd && req.Before == nil {
+		return ctx, nil, nil
+	}
+	skip := relay.GetSkip(ctx)
+	skip.TotalCount = true
+	return relay.WithSkip(ctx, skip), &estimate, nil
+}
+
+// countConcurrently starts counting the rows in a goroutine and skips the total count of the adapter via the returned context,
+// wait returns the count and cancel stops counting. wait is nil if the rows cannot be counted concurrently, see WithConcurrentCount.
+func countConcurrently[T any](ctx context.Context, db *gorm.DB, o *options, req *relay.ApplyCursorsRequest) (_ context.Context, wait func() (int, error), cancel func()) {
+	if _, ok := totalCountFromContext(ctx); ok || relay.GetSkip(ctx).TotalCount {
+		return ctx, nil, func() {}
+	}
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return ctx, nil, func() {}
+	}
+	if req.FromEnd && req.Before == nil {
+		return ctx, nil, func() {}
+	}
+
+	countCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var count int
+	var err error
+	go func() {
+		defer close(done)
+		count, err = (&KeysetFinder[T]{db: db, opts: o}).Count(countCtx)
+	}()
+
+	skip := relay.GetSkip(ctx)
+	skip.TotalCount = true
+	return relay.WithSkip(ctx, skip), func() (int, error) {
+		<-done
+		return count, err
+	}, cancel
+}
+
+// approximateCount estimates the rows of db from the planner on postgres, which is based on pg_class.reltuples.
+// It returns nil if db filters the rows, since the estimate of a filtered query can be far off.
+func approximateCount[T any](ctx context.Context, db *gorm.DB, o *options) (*int, error) {
+	if db.Dialector.Name() != "postgres" || o.countQuery != nil || o.distinctNodes {
+		return nil, nil
+	}
+
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return nil, err
+	}
+
+	db = db.WithContext(ctx)
+	if !basedOnModel && db.Statement.Model == nil {
+		db = db.Model(newModel[T]())
+	}
+
+	s, err := parseSchema(db, db.Statement.Model)
+	if err != nil {
+		return nil, err
+	}
+	if hasPredicates(db, s) {
+		return nil, nil
+	}
+
+	var dest any = &[]T{}
+	if basedOnModel {
+		dest = reflect.New(reflect.SliceOf(reflect.TypeOf(db.Statement.Model))).Interface()
+	}
+	_, lines, err := explain(db, dest, "EXPLAIN (FORMAT JSON)")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := parsePlanRows([]byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, err
+	}
+	return &rows, nil
+}
+
+// hasPredicates reports whether db may return fewer rows than its table has,
+// i.e. it has conditions, joins, grouping or an implicit soft delete condition
+func hasPredicates(db *gorm.DB, s *schema.Schema) bool {
+	for _, name := range []string{"WHERE", "GROUP BY", "LIMIT"} {
+		if c, ok := db.Statement.Clauses[name]; ok && c.Expression != nil {
+			if where, ok := c.Expression.(clause.Where); !ok || len(where.Exprs) > 0 {
+				return true
+			}
+		}
+	}
+	if len(db.Statement.Joins) > 0 || db.Statement.Distinct {
+		return true
+	}
+	if !db.Statement.Unscoped {
+		for _, field := range s.Fields {
+			if field.FieldType == deletedAtType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countWithTimeout counts the rows within timeout, it returns nil if the timeout is exceeded
+func countWithTimeout[T any](ctx context.Context, db *gorm.DB, o *options) (*int, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, o.countTimeout)
+	defer cancel()
+
+	count, err := (&KeysetFinder[T]{db: db, opts: o}).Count(timeoutCtx)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &count, nil
+}
+
+// totalCountFromContext returns the total count that has been counted before the adapter runs
+func totalCountFromContext(ctx context.Context) (int, bool) {
+	count, ok := ctx.Value(ctxKeyTotalCount{}).(int)
+	return count, ok
+}
+
+// distinctPrimaryKey makes Count count the distinct primary keys, i.e. `COUNT(DISTINCT(users.id))`
+func distinctPrimaryKey(db *gorm.DB, o *options) (*gorm.DB, error) {
+	s, err := parseSchema(db, db.Statement.Model)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := primaryField(s, o)
+	if err != nil {
+		return nil, err
+	}
+	table := db.Statement.Table
+	if table == "" {
+		table = s.Table
+	}
+	return db.Distinct(table + "." + pk.DBName), nil
+}
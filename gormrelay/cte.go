@@ -0,0 +1,32 @@
+package gormrelay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/relay"
+	"gorm.io/gorm"
+)
+
+// NewCTEAdapter creates a keyset adapter over the rows of cteSQL, e.g. a `WITH RECURSIVE` query.
+// The rows are selected as `SELECT * FROM (cteSQL) AS cte` and counted the same way, not with a `WITH` prefix, since
+// gorm has no clause to build one. So the dialect must accept a `WITH` query as a derived table, as postgres, sqlite
+// and mysql 8 do, while e.g. sqlserver does not.
+// keyColumn must be unique in the rows, it is used as the tiebreaker of the orderBys.
+func NewCTEAdapter[T any](db *gorm.DB, cteSQL string, args []any, keyColumn string, opts ...Option) relay.ApplyCursorsFunc[T] {
+	db = db.Table("(?) AS cte", gorm.Expr(cteSQL, args...)).Session(&gorm.Session{})
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		s, err := parseModelSchema[T](db)
+		if err != nil {
+			return nil, err
+		}
+		field, ok := s.FieldsByDBName[keyColumn]
+		if !ok {
+			if suggestion := suggest(keyColumn, s.DBNames); suggestion != "" {
+				return nil, errors.Errorf("missing column %q in schema, did you mean %q?", keyColumn, suggestion)
+			}
+			return nil, errors.Errorf("missing column %q in schema", keyColumn)
+		}
+		return NewKeysetAdapter[T](db, append(opts[:len(opts):len(opts)], WithPrimaryKey(field.Name))...)(ctx, req)
+	}
+}
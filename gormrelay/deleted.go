@@ -0,0 +1,64 @@
+package gormrelay
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// countDeleted counts the soft-deleted rows matching the conditions of db, it returns nil if db is unscoped,
+// since its total count already includes them
+func countDeleted[T any](ctx context.Context, db *gorm.DB, o *options) (*int, error) {
+	if db.Statement.Unscoped {
+		return nil, nil
+	}
+
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return nil, err
+	}
+
+	if db.Statement.Context != ctx {
+		db = db.WithContext(ctx)
+	}
+
+	if !basedOnModel && db.Statement.Model == nil {
+		db = db.Model(newModel[T]())
+	}
+
+	s, err := parseSchema(db, db.Statement.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	var deletedAt string
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAtType {
+			deletedAt = field.DBName
+			break
+		}
+	}
+	if deletedAt == "" {
+		return nil, errors.Errorf("missing gorm.DeletedAt field in %s for deleted breakdown", s.Name)
+	}
+
+	db = db.Unscoped().Where("? IS NOT NULL", clause.Column{Table: clause.CurrentTable, Name: deletedAt})
+	if o.distinctNodes {
+		db, err = distinctPrimaryKey(db, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return nil, errors.Wrap(err, "count deleted")
+	}
+	deletedCount := int(count)
+	return &deletedCount, nil
+}
@@ -0,0 +1,244 @@
+package gormrelay
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// explain runs `<explain> <sql>` for the query that finding into dest would run and returns the lines of the plan
+func explain(db *gorm.DB, dest any, explain string) (*gorm.Statement, []string, error) {
+	stmt := db.Session(&gorm.Session{DryRun: true}).Find(dest).Statement
+	if stmt.Error != nil {
+		return nil, nil, stmt.Error
+	}
+
+	rows, err := stmt.ConnPool.QueryContext(stmt.Context, explain+" "+stmt.SQL.String(), stmt.Vars...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "explain")
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, nil, errors.Wrap(err, "scan explain")
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "explain")
+	}
+	return stmt, lines, nil
+}
+
+// warnSeqScan explains the query that finding into dest would run,
+// and reports it to the seq scan warning if the plan contains a sequential scan. Only postgres is supported.
+func warnSeqScan(db *gorm.DB, o *options, dest any) error {
+	if o.seqScanWarning == nil || db.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	stmt, lines, err := explain(db, dest, "EXPLAIN")
+	if err != nil {
+		return err
+	}
+
+	if lo.SomeBy(lines, func(line string) bool { return strings.Contains(line, "Seq Scan") }) {
+		o.seqScanWarning(stmt.Context, db.Dialector.Explain(stmt.SQL.String(), stmt.Vars...))
+	}
+	return nil
+}
+
+// IndexUsage is a plan node that scans a relation or an index
+type IndexUsage struct {
+	NodeType string  // e.g. Seq Scan, Index Scan, Index Only Scan, Bitmap Index Scan
+	Relation string  // empty if the node does not report it, e.g. Bitmap Index Scan
+	Index    string  // empty if no index is used
+	Cost     float64 // total cost estimated by the planner
+}
+
+type explainPlan struct {
+	NodeType  string         `json:"Node Type"`
+	Relation  string         `json:"Relation Name"`
+	Index     string         `json:"Index Name"`
+	TotalCost float64        `json:"Total Cost"`
+	Rows      float64        `json:"Plan Rows"`
+	Plans     []*explainPlan `json:"Plans"`
+}
+
+// parseIndexUsages collects the scan nodes of a plan in `EXPLAIN (FORMAT JSON)` output, depth first
+func parseIndexUsages(output []byte) ([]*IndexUsage, error) {
+	var plans []struct {
+		Plan *explainPlan `json:"Plan"`
+	}
+	if err := json.Unmarshal(output, &plans); err != nil {
+		return nil, errors.Wrap(err, "unmarshal plan")
+	}
+
+	var usages []*IndexUsage
+	var walk func(plan *explainPlan)
+	walk = func(plan *explainPlan) {
+		if plan == nil {
+			return
+		}
+		if plan.Relation != "" || plan.Index != "" {
+			usages = append(usages, &IndexUsage{
+				NodeType: plan.NodeType,
+				Relation: plan.Relation,
+				Index:    plan.Index,
+				Cost:     plan.TotalCost,
+			})
+		}
+		for _, child := range plan.Plans {
+			walk(child)
+		}
+	}
+	for _, plan := range plans {
+		walk(plan.Plan)
+	}
+	return usages, nil
+}
+
+// parsePlanRows returns the rows estimated for the top plan node in `EXPLAIN (FORMAT JSON)` output
+func parsePlanRows(output []byte) (int, error) {
+	var plans []struct {
+		Plan *explainPlan `json:"Plan"`
+	}
+	if err := json.Unmarshal(output, &plans); err != nil {
+		return 0, errors.Wrap(err, "unmarshal plan")
+	}
+	if len(plans) == 0 || plans[0].Plan == nil {
+		return 0, errors.New("missing plan")
+	}
+	return int(plans[0].Plan.Rows), nil
+}
+
+// ExplainRequest explains the query that the keyset adapter would run to fetch the page of req without running it,
+// and reports the scans of the plan, e.g. to verify that the orderBys and the filters of db hit the intended index.
+// The cursors of req must be raw keyset cursors as encoded by cursor.EncodeKeysetCursor, i.e. not wrapped by Base64 or GCM.
+// Only postgres is supported.
+func ExplainRequest[T any](ctx context.Context, db *gorm.DB, req *relay.PaginateRequest[T], opts ...Option) ([]*IndexUsage, error) {
+	if db.Dialector.Name() != "postgres" {
+		return nil, errors.Errorf("explain is not supported on %s", db.Dialector.Name())
+	}
+	if (req.First == nil) == (req.Last == nil) {
+		return nil, errors.New("either first or last must be set")
+	}
+
+	o := newOptions(opts...)
+	orderBys, err := prepareOrderBys[T](db, o, req.OrderBys)
+	if err != nil {
+		return nil, err
+	}
+	keys := lo.Map(orderBys, func(orderBy relay.OrderBy, _ int) string { return orderBy.Field })
+
+	var after, before *map[string]any
+	if req.After != nil {
+		keyset, err := cursor.DecodeKeysetCursor[T](*req.After, keys)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid after cursor")
+		}
+		after = &keyset
+	}
+	if req.Before != nil {
+		keyset, err := cursor.DecodeKeysetCursor[T](*req.Before, keys)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid before cursor")
+		}
+		before = &keyset
+	}
+
+	var limit int
+	if req.First != nil {
+		limit = *req.First + 1
+	} else {
+		limit = *req.Last + 1
+	}
+
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return nil, err
+	}
+
+	db = db.WithContext(ctx)
+	if !basedOnModel && db.Statement.Model == nil {
+		db = db.Model(newModel[T]())
+	}
+
+	var dest any = &[]T{}
+	if basedOnModel {
+		dest = reflect.New(reflect.SliceOf(reflect.TypeOf(db.Statement.Model))).Interface()
+	}
+
+	db = db.Scopes(scopeKeyset(o, after, before, orderBys, limit, req.Last != nil))
+	_, lines, err := explain(db, dest, "EXPLAIN (FORMAT JSON)")
+	if err != nil {
+		return nil, err
+	}
+	return parseIndexUsages([]byte(strings.Join(lines, "\n")))
+}
+
+// ExplainKeyset renders the keyset predicate that the keyset adapter would add for the after cursor and orderBys
+// without running any query, e.g. `("users"."age" > $1 OR ("users"."age" = $2 AND "users"."id" < $3))` with its bound values.
+// The predicate for a before cursor is the same with the comparisons reversed.
+// after must be a raw keyset cursor as encoded by cursor.EncodeKeysetCursor, and opts should be the same as the adapter's.
+func ExplainKeyset[T any](db *gorm.DB, after string, orderBys []relay.OrderBy, opts ...Option) (sql string, vars []any, err error) {
+	o := newOptions(opts...)
+	orderBys, err = prepareOrderBys[T](db, o, orderBys)
+	if err != nil {
+		return "", nil, err
+	}
+	keys := lo.Map(orderBys, func(orderBy relay.OrderBy, _ int) string { return orderBy.Field })
+	if len(keys) == 0 {
+		return "", nil, errors.WithStack(relay.ErrMissingOrderBy)
+	}
+
+	keyset, err := cursor.DecodeKeysetCursor[T](after, keys)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "invalid after cursor")
+	}
+
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return "", nil, err
+	}
+	db = db.Session(&gorm.Session{DryRun: true})
+	if !basedOnModel && db.Statement.Model == nil {
+		db = db.Model(newModel[T]())
+	}
+
+	s, err := parseSchema(db, db.Statement.Model)
+	if err != nil {
+		return "", nil, err
+	}
+	terms, err := buildOrderTerms(db, s, orderBys, o)
+	if err != nil {
+		return "", nil, err
+	}
+	expr, err := createWhereExpr(terms, keyset, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt := &gorm.Statement{
+		DB:        db,
+		Table:     db.Statement.Table,
+		TableExpr: db.Statement.TableExpr,
+		Clauses:   map[string]clause.Clause{},
+	}
+	if stmt.Table == "" {
+		stmt.Table = s.Table
+	}
+	expr.Build(stmt)
+	return stmt.SQL.String(), stmt.Vars, nil
+}
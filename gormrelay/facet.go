@@ -0,0 +1,107 @@
+package gormrelay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// facetColumn prepares db to query the filtered set of T and returns the column of the field
+func facetColumn[T any](ctx context.Context, db *gorm.DB, fieldName string) (*gorm.DB, clause.Column, error) {
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return nil, clause.Column{}, err
+	}
+
+	if db.Statement.Context != ctx {
+		db = db.WithContext(ctx)
+	}
+
+	if !basedOnModel && db.Statement.Model == nil {
+		db = db.Model(newModel[T]())
+	}
+
+	s, err := parseSchema(db, db.Statement.Model)
+	if err != nil {
+		return nil, clause.Column{}, err
+	}
+
+	field, ok := s.FieldsByName[fieldName]
+	if !ok {
+		return nil, clause.Column{}, missingFieldError(s, fieldName)
+	}
+	return db, clause.Column{Table: clause.CurrentTable, Name: field.DBName}, nil
+}
+
+// countFacets runs `SELECT field, COUNT(*) ... GROUP BY field` over the filtered set
+func countFacets[T any](ctx context.Context, db *gorm.DB, fieldName string) (map[string]int, error) {
+	db, column, err := facetColumn[T](ctx, db, fieldName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Select("?, COUNT(*)", column).Clauses(clause.GroupBy{Columns: []clause.Column{column}}).Rows()
+	if err != nil {
+		return nil, errors.Wrap(err, "count facets")
+	}
+	defer rows.Close()
+
+	facets := make(map[string]int)
+	for rows.Next() {
+		var value any
+		var count int
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, errors.Wrap(err, "scan facet")
+		}
+		var key string
+		switch v := value.(type) {
+		case nil:
+		case []byte:
+			key = string(v)
+		default:
+			key = fmt.Sprint(v)
+		}
+		facets[key] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "count facets")
+	}
+	return facets, nil
+}
+
+// listDistinctValues runs `SELECT DISTINCT field ... ORDER BY field LIMIT limit` over the filtered set
+func listDistinctValues[T any](ctx context.Context, db *gorm.DB, fieldName string, limit int) ([]any, error) {
+	db, column, err := facetColumn[T](ctx, db, fieldName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Clauses(
+		clause.Select{Distinct: true, Columns: []clause.Column{column}},
+		clause.OrderBy{Columns: []clause.OrderByColumn{{Column: column}}},
+		clause.Limit{Limit: &limit},
+	).Rows()
+	if err != nil {
+		return nil, errors.Wrap(err, "list distinct values")
+	}
+	defer rows.Close()
+
+	values := make([]any, 0, limit)
+	for rows.Next() {
+		var value any
+		if err := rows.Scan(&value); err != nil {
+			return nil, errors.Wrap(err, "scan distinct value")
+		}
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "list distinct values")
+	}
+	return values, nil
+}
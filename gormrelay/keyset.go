@@ -10,35 +10,30 @@ import (
 	"github.com/theplant/relay/cursor"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
-	"gorm.io/gorm/schema"
 )
 
-func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[string]any, reverse bool) (clause.Expression, error) {
-	ors := make([]clause.Expression, 0, len(orderBys))
-	eqs := make([]clause.Expression, 0, len(orderBys))
-	for i, orderBy := range orderBys {
-		v, ok := keyset[orderBy.Field]
+func createWhereExpr(terms []*orderTerm, keyset map[string]any, reverse bool) (clause.Expression, error) {
+	ors := make([]clause.Expression, 0, len(terms))
+	eqs := make([]clause.Expression, 0, len(terms))
+	for i, term := range terms {
+		v, ok := keyset[term.Key]
 		if !ok {
-			return nil, errors.Errorf("missing field %q in keyset", orderBy.Field)
+			return nil, errors.Errorf("missing field %q in keyset", term.Key)
 		}
-
-		field, ok := s.FieldsByName[orderBy.Field]
-		if !ok {
-			return nil, errors.Errorf("missing field %q in schema", orderBy.Field)
+		if term.Value != nil {
+			v = term.Value(v)
 		}
 
-		desc := orderBy.Desc
+		desc := term.Desc
 		if reverse {
 			desc = !desc
 		}
 
-		column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
-
 		var expr clause.Expression
 		if desc {
-			expr = clause.Lt{Column: column, Value: v}
+			expr = clause.Lt{Column: term.Column, Value: v}
 		} else {
-			expr = clause.Gt{Column: column, Value: v}
+			expr = clause.Gt{Column: term.Column, Value: v}
 		}
 
 		ands := make([]clause.Expression, len(eqs)+1)
@@ -46,8 +41,8 @@ func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[stri
 		ands[len(eqs)] = expr
 		ors = append(ors, clause.And(ands...))
 
-		if i < len(orderBys)-1 {
-			eqs = append(eqs, clause.Eq{Column: column, Value: v})
+		if i < len(terms)-1 {
+			eqs = append(eqs, clause.Eq{Column: term.Column, Value: v})
 		}
 	}
 	return clause.And(clause.Or(ors...)), nil
@@ -88,7 +83,7 @@ func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[stri
 //		clause.Limit{Limit: &limit},
 //
 // )
-func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromEnd bool) func(db *gorm.DB) *gorm.DB {
+func scopeKeyset(o *options, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromEnd bool) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
 		if db.Statement.Model == nil {
 			db.AddError(errors.New("model is nil"))
@@ -101,10 +96,16 @@ func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit
 			return db
 		}
 
+		terms, err := buildOrderTerms(db, s, orderBys, o)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+
 		var exprs []clause.Expression
 
 		if after != nil {
-			expr, err := createWhereExpr(s, orderBys, *after, false)
+			expr, err := createWhereExpr(terms, *after, false)
 			if err != nil {
 				db.AddError(err)
 				return db
@@ -113,7 +114,7 @@ func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit
 		}
 
 		if before != nil {
-			expr, err := createWhereExpr(s, orderBys, *before, true)
+			expr, err := createWhereExpr(terms, *before, true)
 			if err != nil {
 				db.AddError(err)
 				return db
@@ -121,25 +122,8 @@ func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit
 			exprs = append(exprs, expr)
 		}
 
-		if len(orderBys) > 0 {
-			orderByColumns := make([]clause.OrderByColumn, 0, len(orderBys))
-			for _, orderBy := range orderBys {
-				field, ok := s.FieldsByName[orderBy.Field]
-				if !ok {
-					db.AddError(errors.Errorf("missing field %q in schema", orderBy.Field))
-					return db
-				}
-
-				desc := orderBy.Desc
-				if fromEnd {
-					desc = !desc
-				}
-				orderByColumns = append(orderByColumns, clause.OrderByColumn{
-					Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
-					Desc:   desc,
-				})
-			}
-			exprs = append(exprs, clause.OrderBy{Columns: orderByColumns})
+		if len(terms) > 0 {
+			exprs = append(exprs, orderByClause(terms, fromEnd))
 		}
 
 		if limit > 0 {
@@ -148,11 +132,24 @@ func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit
 			db.AddError(errors.New("limit must be greater than 0"))
 		}
 
+		if o.distinctNodes {
+			db = db.Distinct()
+		}
+
+		if len(o.selectFields) > 0 {
+			sel, err := selectClause(db, s, o, orderBys)
+			if err != nil {
+				db.AddError(err)
+				return db
+			}
+			exprs = append(exprs, sel)
+		}
+
 		return db.Clauses(exprs...)
 	}
 }
 
-func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromEnd bool) ([]T, error) {
+func findByKeyset[T any](db *gorm.DB, o *options, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromEnd bool) ([]T, error) {
 	var nodes []T
 	if limit == 0 {
 		return nodes, nil
@@ -168,7 +165,11 @@ func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []
 		sliceType := reflect.SliceOf(modelType)
 		nodesVal := reflect.New(sliceType).Elem()
 
-		err := db.Scopes(scopeKeyset(after, before, orderBys, limit, fromEnd)).Find(nodesVal.Addr().Interface()).Error
+		db = db.Scopes(scopeKeyset(o, after, before, orderBys, limit, fromEnd))
+		if err := warnSeqScan(db, o, nodesVal.Addr().Interface()); err != nil {
+			return nil, err
+		}
+		err := db.Find(nodesVal.Addr().Interface()).Error
 		if err != nil {
 			return nil, errors.Wrap(err, "find")
 		}
@@ -189,7 +190,11 @@ func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []
 		db = db.Model(t)
 	}
 
-	err = db.Scopes(scopeKeyset(after, before, orderBys, limit, fromEnd)).Find(&nodes).Error
+	db = db.Scopes(scopeKeyset(o, after, before, orderBys, limit, fromEnd))
+	if err := warnSeqScan(db, o, &nodes); err != nil {
+		return nil, err
+	}
+	err = db.Find(&nodes).Error
 	if err != nil {
 		return nil, errors.Wrap(err, "find")
 	}
@@ -200,11 +205,12 @@ func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []
 }
 
 type KeysetFinder[T any] struct {
-	db *gorm.DB
+	db   *gorm.DB
+	opts *options
 }
 
-func NewKeysetFinder[T any](db *gorm.DB) *KeysetFinder[T] {
-	return &KeysetFinder[T]{db: db}
+func NewKeysetFinder[T any](db *gorm.DB, opts ...Option) *KeysetFinder[T] {
+	return &KeysetFinder[T]{db: db, opts: newOptions(opts...)}
 }
 
 func (a *KeysetFinder[T]) Find(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromEnd bool) ([]T, error) {
@@ -217,7 +223,7 @@ func (a *KeysetFinder[T]) Find(ctx context.Context, after, before *map[string]an
 		db = db.WithContext(ctx)
 	}
 
-	nodes, err := findByKeyset[T](db, after, before, orderBys, limit, fromEnd)
+	nodes, err := findByKeyset[T](db, a.opts, after, before, orderBys, limit, fromEnd)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +231,84 @@ func (a *KeysetFinder[T]) Find(ctx context.Context, after, before *map[string]an
 	return nodes, nil
 }
 
+// FindStream scans the nodes one by one with Rows as the seq is ranged over, see cursor.KeysetStreamFinder.
+// It does not stream with WithStatementTimeout, since the rows would outlive the transaction of the timeout.
+func (a *KeysetFinder[T]) FindStream(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int) (relay.Seq2[T, error], error) {
+	if a.opts.statementTimeout > 0 {
+		return nil, nil
+	}
+
+	db := a.db
+	if db.Statement.Context != ctx {
+		db = db.WithContext(ctx)
+	}
+
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return nil, err
+	}
+	if !basedOnModel && db.Statement.Model == nil {
+		db = db.Model(newModel[T]())
+	}
+
+	// newNode returns the destination to scan a row into and the node holding it
+	newNode := func() (any, T) {
+		var node T
+		if basedOnModel {
+			dest := reflect.New(reflect.TypeOf(db.Statement.Model).Elem())
+			return dest.Interface(), dest.Interface().(T)
+		}
+		if rt := reflect.TypeOf(node); rt != nil && rt.Kind() == reflect.Ptr {
+			node = reflect.New(rt.Elem()).Interface().(T)
+			return node, node
+		}
+		return &node, node
+	}
+
+	db = db.Scopes(scopeKeyset(a.opts, after, before, orderBys, limit, false))
+	return func(yield func(T, error) bool) {
+		var zero T
+		if limit == 0 {
+			return
+		}
+
+		dest, _ := newNode()
+		if err := warnSeqScan(db, a.opts, dest); err != nil {
+			yield(zero, err)
+			return
+		}
+
+		rows, err := db.Rows()
+		if err != nil {
+			yield(zero, errors.Wrap(err, "find"))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			dest, node := newNode()
+			if err := db.ScanRows(rows, dest); err != nil {
+				yield(zero, errors.Wrap(err, "scan"))
+				return
+			}
+			if ptr, ok := dest.(*T); ok {
+				node = *ptr
+			}
+			if !yield(node, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, errors.Wrap(err, "find"))
+		}
+	}, nil
+}
+
 func (a *KeysetFinder[T]) Count(ctx context.Context) (int, error) {
+	if count, ok := totalCountFromContext(ctx); ok {
+		return count, nil
+	}
+
 	db := a.db
 
 	basedOnModel, err := shouldBasedOnModel[T](db)
@@ -242,6 +325,15 @@ func (a *KeysetFinder[T]) Count(ctx context.Context) (int, error) {
 		db = db.Model(t)
 	}
 
+	if a.opts.countQuery != nil {
+		db = a.opts.countQuery(db.Session(&gorm.Session{}))
+	} else if a.opts.distinctNodes {
+		db, err = distinctPrimaryKey(db, a.opts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	var totalCount int64
 	if err := db.Count(&totalCount).Error; err != nil {
 		return 0, errors.Wrap(err, "count")
@@ -249,6 +341,58 @@ func (a *KeysetFinder[T]) Count(ctx context.Context) (int, error) {
 	return int(totalCount), nil
 }
 
-func NewKeysetAdapter[T any](db *gorm.DB) relay.ApplyCursorsFunc[T] {
-	return cursor.NewKeysetAdapter(NewKeysetFinder[T](db))
+func NewKeysetAdapter[T any](db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts...)
+	return withStatementTimeout(db, o, func(db *gorm.DB) relay.ApplyCursorsFunc[T] {
+		return wrapAdapter(db, o, cursor.NewKeysetAdapter[T](&KeysetFinder[T]{db: db, opts: o}))
+	})
+}
+
+// KeysetCursorForPrimaryKey fetches the node whose primary key (see WithPrimaryKey) is pk and builds its keyset cursor for orderBys,
+// the orderBys are prepared by the options the same way as NewKeysetAdapter does, so opts should be the same as the adapter's.
+// The cursor is the raw one, see cursor.KeysetCursorForNode.
+func KeysetCursorForPrimaryKey[T any](ctx context.Context, db *gorm.DB, pk any, orderBys []relay.OrderBy, opts ...Option) (string, error) {
+	o := newOptions(opts...)
+
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return "", err
+	}
+
+	db = db.WithContext(ctx)
+	if !basedOnModel && db.Statement.Model == nil {
+		db = db.Model(newModel[T]())
+	}
+
+	s, err := parseSchema(db, db.Statement.Model)
+	if err != nil {
+		return "", err
+	}
+	field, err := primaryField(s, o)
+	if err != nil {
+		return "", err
+	}
+
+	orderBys, err = prepareOrderBys[T](db, o, orderBys)
+	if err != nil {
+		return "", err
+	}
+
+	var dest any
+	if basedOnModel {
+		dest = reflect.New(reflect.TypeOf(db.Statement.Model).Elem()).Interface()
+	} else {
+		dest = newModel[T]()
+	}
+	if err := db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: pk}).Take(dest).Error; err != nil {
+		return "", errors.Wrap(err, "find node")
+	}
+
+	var node T
+	if v, ok := dest.(T); ok {
+		node = v
+	} else {
+		node = reflect.ValueOf(dest).Elem().Interface().(T)
+	}
+	return cursor.KeysetCursorForNode(node, orderBys)
 }
@@ -2,13 +2,16 @@ package gormrelay
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
 	"github.com/theplant/relay"
 	"github.com/theplant/relay/cursor"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 func mustEncodeKeysetCursor[T any](node T, keys []string) string {
@@ -23,6 +26,7 @@ func TestScopeKeyset(t *testing.T) {
 	{
 		db.ToSQL(func(tx *gorm.DB) *gorm.DB {
 			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(),
 				&map[string]interface{}{"Age": 85},
 				nil,
 				[]relay.OrderBy{
@@ -38,6 +42,7 @@ func TestScopeKeyset(t *testing.T) {
 	{
 		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
 			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(),
 				&map[string]interface{}{"Age": 85},
 				nil,
 				[]relay.OrderBy{
@@ -55,6 +60,7 @@ func TestScopeKeyset(t *testing.T) {
 		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
 			// with table alias
 			tx = tx.Table("company_users AS u").Model(&User{}).Scopes(scopeKeyset(
+				newOptions(),
 				&map[string]interface{}{"Age": 85},
 				nil,
 				[]relay.OrderBy{
@@ -71,6 +77,7 @@ func TestScopeKeyset(t *testing.T) {
 	{
 		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
 			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(),
 				&map[string]interface{}{"Age": 85},
 				&map[string]interface{}{"Age": 88},
 				[]relay.OrderBy{
@@ -87,6 +94,7 @@ func TestScopeKeyset(t *testing.T) {
 	{
 		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
 			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(),
 				&map[string]interface{}{"Age": 85, "Name": "name15"},
 				&map[string]interface{}{"Age": 88, "Name": "name12"},
 				[]relay.OrderBy{
@@ -104,6 +112,7 @@ func TestScopeKeyset(t *testing.T) {
 	{
 		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
 			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(),
 				&map[string]interface{}{"Age": 85, "Name": "name15"},
 				&map[string]interface{}{"Age": 88, "Name": "name12"},
 				[]relay.OrderBy{
@@ -123,6 +132,7 @@ func TestScopeKeyset(t *testing.T) {
 			// with extra where
 			tx = tx.Model(&User{}).Where("name LIKE ?", "name%").
 				Scopes(scopeKeyset(
+					newOptions(),
 					&map[string]interface{}{"Age": 85, "Name": "name15"},
 					&map[string]interface{}{"Age": 88, "Name": "name12"},
 					[]relay.OrderBy{
@@ -137,6 +147,118 @@ func TestScopeKeyset(t *testing.T) {
 		})
 		require.Equal(t, `SELECT * FROM "users" WHERE name LIKE 'name%' AND (("users"."age" > 85 OR ("users"."age" = 85 AND "users"."name" < 'name15')) AND ("users"."age" < 88 OR ("users"."age" = 88 AND "users"."name" > 'name12'))) ORDER BY "users"."age","users"."name" DESC LIMIT 10`, sql)
 	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with seeded random order
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(WithSeededRandomOrder("seed")),
+				&map[string]interface{}{"ID": float64(5)},
+				nil,
+				[]relay.OrderBy{
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE (md5(CAST("users"."id" AS TEXT) || 'seed') > '`+seededHash(5, "seed")+`' OR (md5(CAST("users"."id" AS TEXT) || 'seed') = '`+seededHash(5, "seed")+`' AND "users"."id" > 5)) ORDER BY md5(CAST("users"."id" AS TEXT) || 'seed'),"users"."id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with pinned first
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(WithPinnedFirst([]any{7, 50})),
+				&map[string]interface{}{"Age": float64(20), "ID": float64(7)},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Age", Desc: true},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE (CASE WHEN "users"."id" IN (7,50) THEN 0 ELSE 1 END > 0 OR (CASE WHEN "users"."id" IN (7,50) THEN 0 ELSE 1 END = 0 AND "users"."age" < 20) OR (CASE WHEN "users"."id" IN (7,50) THEN 0 ELSE 1 END = 0 AND "users"."age" = 20 AND "users"."id" > 7)) ORDER BY CASE WHEN "users"."id" IN (7,50) THEN 0 ELSE 1 END,"users"."age" DESC,"users"."id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with select columns
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(WithSelectColumns([]string{"Name", "ID"})),
+				&map[string]interface{}{"Age": 85, "ID": float64(15)},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Age", Desc: true},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT "users"."name","users"."id","users"."age" FROM "users" WHERE ("users"."age" < 85 OR ("users"."age" = 85 AND "users"."id" > 15)) ORDER BY "users"."age" DESC,"users"."id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with select columns, distinct and sort key
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(WithSelectColumns([]string{"Age"}), WithDistinctNodes(), WithSortKey("Name", "age || '-' || name")),
+				nil,
+				nil,
+				[]relay.OrderBy{
+					{Field: "Name", Desc: false},
+				},
+				10,
+				false,
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT DISTINCT "users"."age",(age || '-' || name) AS "name","users"."id" FROM "users" ORDER BY (age || '-' || name) LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with fold
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(),
+				&map[string]interface{}{"Name": "Name15", "ID": float64(15)},
+				&map[string]interface{}{"Name": "NAME20", "ID": float64(20)},
+				[]relay.OrderBy{
+					{Field: "Name", Desc: false, Fold: true},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE (LOWER("users"."name") > LOWER('Name15') OR (LOWER("users"."name") = LOWER('Name15') AND "users"."id" > 15)) AND (LOWER("users"."name") < LOWER('NAME20') OR (LOWER("users"."name") = LOWER('NAME20') AND "users"."id" < 20)) ORDER BY LOWER("users"."name"),"users"."id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with sort key
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				newOptions(WithSortKey("Name", "age || '-' || name")),
+				&map[string]interface{}{"Name": "20-name20", "ID": float64(20)},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Name", Desc: true},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE ((age || '-' || name) < '20-name20' OR ((age || '-' || name) = '20-name20' AND "users"."id" > 20)) ORDER BY (age || '-' || name) DESC,"users"."id" LIMIT 10`, sql)
+	}
 }
 
 func TestKeysetCursor(t *testing.T) {
@@ -741,6 +863,114 @@ func TestKeysetCursor(t *testing.T) {
 				)),
 			},
 		},
+		{
+			name:             "After cursor 9, Before cursor 19, First 3 (window larger than limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 9 + 1, Name: "name9", Age: 91}, primaryOrderByKeys,
+				)),
+				Before: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 19 + 1, Name: "name19", Age: 81}, primaryOrderByKeys,
+				)),
+				First: lo.ToPtr(3),
+			},
+			expectedEdgesLen:   3,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 10 + 1, Name: "name10", Age: 90}, primaryOrderByKeys,
+				)),
+				EndCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 12 + 1, Name: "name12", Age: 88}, primaryOrderByKeys,
+				)),
+			},
+		},
+		{
+			name:             "After cursor 9, Before cursor 19, Last 3 (window larger than limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 9 + 1, Name: "name9", Age: 91}, primaryOrderByKeys,
+				)),
+				Before: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 19 + 1, Name: "name19", Age: 81}, primaryOrderByKeys,
+				)),
+				Last: lo.ToPtr(3),
+			},
+			expectedEdgesLen:   3,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 16 + 1, Name: "name16", Age: 84}, primaryOrderByKeys,
+				)),
+				EndCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 18 + 1, Name: "name18", Age: 82}, primaryOrderByKeys,
+				)),
+			},
+		},
+		{
+			name:             "After cursor 9, Before cursor 19, First 9 (window equal to limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 9 + 1, Name: "name9", Age: 91}, primaryOrderByKeys,
+				)),
+				Before: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 19 + 1, Name: "name19", Age: 81}, primaryOrderByKeys,
+				)),
+				First: lo.ToPtr(9),
+			},
+			expectedEdgesLen:   9,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 10 + 1, Name: "name10", Age: 90}, primaryOrderByKeys,
+				)),
+				EndCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 18 + 1, Name: "name18", Age: 82}, primaryOrderByKeys,
+				)),
+			},
+		},
+		{
+			name:             "After cursor 9, Before cursor 19, Last 20 (window smaller than limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 9 + 1, Name: "name9", Age: 91}, primaryOrderByKeys,
+				)),
+				Before: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 19 + 1, Name: "name19", Age: 81}, primaryOrderByKeys,
+				)),
+				Last: lo.ToPtr(20),
+			},
+			expectedEdgesLen:   9,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 10 + 1, Name: "name10", Age: 90}, primaryOrderByKeys,
+				)),
+				EndCursor: lo.ToPtr(mustEncodeKeysetCursor(
+					&User{ID: 18 + 1, Name: "name18", Age: 82}, primaryOrderByKeys,
+				)),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -785,6 +1015,7 @@ func TestKeysetEmptyOrderBys(t *testing.T) {
 		First: lo.ToPtr(10),
 	})
 	require.ErrorContains(t, err, "no keys to encode cursor, orderBys must be set for keyset")
+	require.ErrorIs(t, err, relay.ErrMissingOrderBy)
 	require.Nil(t, conn)
 }
 
@@ -831,3 +1062,195 @@ func TestKeysetInvalidCursor(t *testing.T) {
 	require.ErrorContains(t, err, `unmarshal cursor`)
 	require.Nil(t, conn)
 }
+
+type Post struct {
+	ID        int       `gorm:"primarykey;not null;index:idx_posts_status_created_at_id,priority:3"`
+	Status    string    `gorm:"not null;index:idx_posts_status_created_at_id,priority:1"`
+	CreatedAt time.Time `gorm:"not null;index:idx_posts_status_created_at_id,priority:2"`
+}
+
+func TestKeysetUsesCompositeIndex(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS posts").Error)
+	require.NoError(t, db.AutoMigrate(&Post{}))
+	t.Cleanup(func() {
+		require.NoError(t, db.Exec("DROP TABLE IF EXISTS posts").Error)
+	})
+
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	posts := make([]*Post, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		posts = append(posts, &Post{
+			Status:    lo.Ternary(i%10 == 0, "PUBLISHED", "DRAFT"),
+			CreatedAt: createdAt.Add(time.Duration(i%500) * time.Minute),
+		})
+	}
+	require.NoError(t, db.Session(&gorm.Session{Logger: logger.Discard}).CreateInBatches(posts, 1000).Error)
+	require.NoError(t, db.Exec("ANALYZE posts").Error)
+
+	// status is pinned by the filter, so the keyset on (created_at, id) can walk the composite index in order
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&Post{}).Where("status = ?", "PUBLISHED").Scopes(scopeKeyset(
+			newOptions(),
+			&map[string]any{"CreatedAt": createdAt.Add(100 * time.Minute), "ID": 1001},
+			nil,
+			[]relay.OrderBy{
+				{Field: "CreatedAt", Desc: false},
+				{Field: "ID", Desc: false},
+			},
+			10,
+			false,
+		)).Find(&[]*Post{})
+	})
+
+	var plan []string
+	require.NoError(t, db.Raw("EXPLAIN "+sql).Scan(&plan).Error)
+	require.Contains(t, strings.Join(plan, "\n"), "idx_posts_status_created_at_id", sql)
+
+	after, err := cursor.EncodeKeysetCursor(&Post{ID: 1001, CreatedAt: createdAt.Add(100 * time.Minute)}, []string{"CreatedAt", "ID"})
+	require.NoError(t, err)
+	usages, err := ExplainRequest(context.Background(), db.Where("status = ?", "PUBLISHED").Session(&gorm.Session{}), &relay.PaginateRequest[*Post]{
+		After: &after,
+		First: lo.ToPtr(10),
+		OrderBys: []relay.OrderBy{
+			{Field: "CreatedAt", Desc: false},
+			{Field: "ID", Desc: false},
+		},
+	})
+	require.NoError(t, err)
+	require.Contains(t, lo.Map(usages, func(usage *IndexUsage, _ int) string { return usage.Index }), "idx_posts_status_created_at_id")
+}
+
+func TestParseIndexUsages(t *testing.T) {
+	usages, err := parseIndexUsages([]byte(`[
+  {
+    "Plan": {
+      "Node Type": "Limit",
+      "Total Cost": 12.5,
+      "Plans": [
+        {
+          "Node Type": "Bitmap Heap Scan",
+          "Relation Name": "posts",
+          "Total Cost": 12.3,
+          "Plans": [
+            {
+              "Node Type": "Bitmap Index Scan",
+              "Index Name": "idx_posts_status_created_at_id",
+              "Total Cost": 4.2
+            }
+          ]
+        },
+        {
+          "Node Type": "Seq Scan",
+          "Relation Name": "users",
+          "Total Cost": 1.5
+        }
+      ]
+    }
+  }
+]`))
+	require.NoError(t, err)
+	require.Equal(t, []*IndexUsage{
+		{NodeType: "Bitmap Heap Scan", Relation: "posts", Cost: 12.3},
+		{NodeType: "Bitmap Index Scan", Index: "idx_posts_status_created_at_id", Cost: 4.2},
+		{NodeType: "Seq Scan", Relation: "users", Cost: 1.5},
+	}, usages)
+
+	_, err = parseIndexUsages([]byte(`invalid`))
+	require.ErrorContains(t, err, "unmarshal plan")
+}
+
+func TestExplainKeyset(t *testing.T) {
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: false},
+		{Field: "Name", Desc: true},
+	}
+
+	sql, vars, err := ExplainKeyset[*User](db, `{"Age":85,"Name":"name15"}`, orderBys)
+	require.NoError(t, err)
+	require.Equal(t, `("users"."age" > $1 OR ("users"."age" = $2 AND "users"."name" < $3))`, sql)
+	require.Equal(t, []any{int64(85), int64(85), "name15"}, vars)
+
+	sql, _, err = ExplainKeyset[*User](db.Table("company_users AS u"), `{"Age":85,"Name":"name15"}`, orderBys)
+	require.NoError(t, err)
+	require.Equal(t, `("u"."age" > $1 OR ("u"."age" = $2 AND "u"."name" < $3))`, sql)
+
+	_, _, err = ExplainKeyset[*User](db, `{"Age":85}`, orderBys)
+	require.ErrorContains(t, err, "invalid after cursor")
+
+	_, _, err = ExplainKeyset[*User](db, `{}`, nil)
+	require.ErrorIs(t, err, relay.ErrMissingOrderBy)
+}
+
+func TestKeysetPaginateStream(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	applyCursorsFunc := cursor.Base64(NewKeysetAdapter[*User](db))
+
+	rsp, err := applyCursorsFunc(context.Background(), &relay.ApplyCursorsRequest{OrderBys: orderBys, Limit: 3, Stream: true})
+	require.NoError(t, err)
+	require.Nil(t, rsp.LazyEdges)
+	require.NotNil(t, rsp.LazyEdgeSeq)
+
+	expected, err := relay.New(applyCursorsFunc).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(30),
+		OrderBys: orderBys,
+	})
+	require.NoError(t, err)
+
+	var edges []*relay.Edge[*User]
+	var after *string
+	for len(edges) < 30 {
+		conn, err := relay.PaginateStream(context.Background(), applyCursorsFunc, &relay.PaginateRequest[*User]{
+			After:    after,
+			First:    lo.ToPtr(10),
+			OrderBys: orderBys,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 100, *conn.TotalCount)
+		conn.Edges(func(edge *relay.Edge[*User], err error) bool {
+			require.NoError(t, err)
+			edges = append(edges, edge)
+			return true
+		})
+		require.True(t, conn.PageInfo.HasNextPage)
+		after = conn.PageInfo.EndCursor
+	}
+	require.Equal(t, expected.Edges, edges)
+
+	// stopping early closes the rows, otherwise the next query would wait for the connection
+	conn, err := relay.PaginateStream(context.Background(), applyCursorsFunc, &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(50),
+		OrderBys: orderBys,
+	})
+	require.NoError(t, err)
+	var count int
+	conn.Edges(func(edge *relay.Edge[*User], err error) bool {
+		require.NoError(t, err)
+		count++
+		return count < 5
+	})
+	require.Equal(t, 5, count)
+	require.Nil(t, conn.PageInfo)
+	var total int64
+	require.NoError(t, db.Model(&User{}).Count(&total).Error)
+	require.Equal(t, int64(100), total)
+
+	// based on the model
+	anyConn, err := relay.PaginateStream(context.Background(), NewKeysetAdapter[any](db.Model(&User{}).Session(&gorm.Session{})), &relay.PaginateRequest[any]{
+		First:    lo.ToPtr(3),
+		OrderBys: orderBys,
+	})
+	require.NoError(t, err)
+	var nodes []any
+	anyConn.Edges(func(edge *relay.Edge[any], err error) bool {
+		require.NoError(t, err)
+		nodes = append(nodes, edge.Node)
+		return true
+	})
+	require.Equal(t, []any{expected.Nodes[0], expected.Nodes[1], expected.Nodes[2]}, nodes)
+	require.True(t, anyConn.PageInfo.HasNextPage)
+}
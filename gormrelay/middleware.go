@@ -0,0 +1,34 @@
+package gormrelay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// EnsurePrimaryOrderByFromSchema is relay.EnsurePrimaryOrderBy with the primary key parsed from the schema of T,
+// or of db.Statement.Model if T is not a struct, so that handlers do not need to repeat it.
+// The prioritized primary field is used if the schema has one, WithPrimaryKey overrides it, so opts should be the same as the adapter's.
+func EnsurePrimaryOrderByFromSchema[T any](db *gorm.DB, opts ...Option) relay.PaginationMiddleware[T] {
+	o := newOptions(opts...)
+	return relay.EnsurePrimaryOrderByFunc[T](func(ctx context.Context) ([]relay.OrderBy, error) {
+		s, err := parseModelSchema[T](db)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := primaryFields(s, o)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, errors.New("missing primary key, use WithPrimaryKey to specify it")
+		}
+		return lo.Map(fields, func(field *schema.Field, _ int) relay.OrderBy {
+			return relay.OrderBy{Field: field.Name}
+		}), nil
+	})
+}
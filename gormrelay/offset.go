@@ -8,16 +8,17 @@ import (
 	"github.com/theplant/relay"
 	"github.com/theplant/relay/cursor"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type OffsetFinder[T any] struct {
-	db *gorm.DB
+	db   *gorm.DB
+	opts *options
 }
 
-func NewOffsetFinder[T any](db *gorm.DB) *OffsetFinder[T] {
+func NewOffsetFinder[T any](db *gorm.DB, opts ...Option) *OffsetFinder[T] {
 	return &OffsetFinder[T]{
-		db: db,
+		db:   db,
+		opts: newOptions(opts...),
 	}
 }
 
@@ -28,6 +29,10 @@ func (a *OffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, sk
 		return nodes, nil
 	}
 
+	if err := checkOffset(a.opts, skip+limit-1); err != nil {
+		return nil, err
+	}
+
 	db := a.db
 	if db.Statement.Context != ctx {
 		db = db.WithContext(ctx)
@@ -49,25 +54,31 @@ func (a *OffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, sk
 		db = db.Model(t)
 	}
 
-	if len(orderBys) > 0 {
+	if a.opts.distinctNodes {
+		db = db.Distinct()
+	}
+
+	if len(orderBys) > 0 || len(a.opts.selectFields) > 0 {
 		s, err := parseSchema(db, db.Statement.Model)
 		if err != nil {
 			return nil, err
 		}
 
-		orderByColumns := make([]clause.OrderByColumn, 0, len(orderBys))
-		for _, orderBy := range orderBys {
-			field, ok := s.FieldsByName[orderBy.Field]
-			if !ok {
-				return nil, errors.Errorf("missing field %q in schema", orderBy.Field)
+		if len(orderBys) > 0 {
+			terms, err := buildOrderTerms(db, s, orderBys, a.opts)
+			if err != nil {
+				return nil, err
 			}
+			db = db.Order(orderByClause(terms, false))
+		}
 
-			orderByColumns = append(orderByColumns, clause.OrderByColumn{
-				Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
-				Desc:   orderBy.Desc,
-			})
+		if len(a.opts.selectFields) > 0 {
+			sel, err := selectClause(db, s, a.opts, orderBys)
+			if err != nil {
+				return nil, err
+			}
+			db = db.Clauses(sel)
 		}
-		db = db.Order(clause.OrderBy{Columns: orderByColumns})
 	}
 
 	if basedOnModel {
@@ -75,6 +86,9 @@ func (a *OffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, sk
 		sliceType := reflect.SliceOf(modelType)
 		nodesVal := reflect.New(sliceType).Elem()
 
+		if err := warnSeqScan(db, a.opts, nodesVal.Addr().Interface()); err != nil {
+			return nil, err
+		}
 		err := db.Find(nodesVal.Addr().Interface()).Error
 		if err != nil {
 			return nil, errors.Wrap(err, "find")
@@ -88,6 +102,9 @@ func (a *OffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, sk
 		return nodes, nil
 	}
 
+	if err := warnSeqScan(db, a.opts, &nodes); err != nil {
+		return nil, err
+	}
 	if err := db.Find(&nodes).Error; err != nil {
 		return nil, errors.Wrap(err, "find")
 	}
@@ -95,6 +112,10 @@ func (a *OffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, sk
 }
 
 func (a *OffsetFinder[T]) Count(ctx context.Context) (int, error) {
+	if count, ok := totalCountFromContext(ctx); ok {
+		return count, nil
+	}
+
 	db := a.db
 
 	basedOnModel, err := shouldBasedOnModel[T](db)
@@ -111,6 +132,15 @@ func (a *OffsetFinder[T]) Count(ctx context.Context) (int, error) {
 		db = db.Model(t)
 	}
 
+	if a.opts.countQuery != nil {
+		db = a.opts.countQuery(db.Session(&gorm.Session{}))
+	} else if a.opts.distinctNodes {
+		db, err = distinctPrimaryKey(db, a.opts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	var totalCount int64
 	if err := db.Count(&totalCount).Error; err != nil {
 		return 0, errors.Wrap(err, "count")
@@ -118,6 +148,51 @@ func (a *OffsetFinder[T]) Count(ctx context.Context) (int, error) {
 	return int(totalCount), nil
 }
 
-func NewOffsetAdapter[T any](db *gorm.DB) relay.ApplyCursorsFunc[T] {
-	return cursor.NewOffsetAdapter(NewOffsetFinder[T](db))
+func NewOffsetAdapter[T any](db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts...)
+	return withStatementTimeout(db, o, func(db *gorm.DB) relay.ApplyCursorsFunc[T] {
+		return wrapAdapter(db, o, checkMaxOffset(o, cursor.NewOffsetAdapter[T](&OffsetFinder[T]{db: db, opts: o})))
+	})
+}
+
+// checkOffset rejects fetching the row at offset beyond the max offset
+func checkOffset(o *options, offset int) error {
+	if o.maxOffset > 0 && offset > o.maxOffset {
+		return errors.Errorf("offset %d exceeds the max offset %d, use keyset pagination for deep pages", offset, o.maxOffset)
+	}
+	return nil
+}
+
+// checkMaxOffset rejects the after and before cursors before counting, OffsetFinder.Find checks the rows to fetch
+// again since the skip of last without before depends on the count
+func checkMaxOffset[T any](o *options, next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+	if o.maxOffset <= 0 {
+		return next
+	}
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		// the offset of the last row to fetch, including the one telling if there is a next page
+		var last int
+		if req.After != nil {
+			after, err := cursor.DecodeOffsetCursor(*req.After)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid after cursor")
+			}
+			last = after + req.Limit
+		}
+		if req.Before != nil {
+			before, err := cursor.DecodeOffsetCursor(*req.Before)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid before cursor")
+			}
+			if req.After == nil || req.FromEnd || before-1 < last {
+				last = before - 1
+			}
+			if err := checkOffset(o, last); err != nil {
+				return nil, errors.Wrap(err, "invalid before cursor")
+			}
+		} else if err := checkOffset(o, last); err != nil {
+			return nil, errors.Wrap(err, "invalid after cursor")
+		}
+		return next(ctx, req)
+	}
 }
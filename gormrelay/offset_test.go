@@ -2,12 +2,15 @@ package gormrelay
 
 import (
 	"context"
+	"encoding/base64"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
 	"github.com/theplant/relay"
 	"github.com/theplant/relay/cursor"
+	"gorm.io/gorm"
 )
 
 func TestOffsetCursor(t *testing.T) {
@@ -580,6 +583,90 @@ func TestOffsetCursor(t *testing.T) {
 				EndCursor:       lo.ToPtr(cursor.EncodeOffsetCursor(3)),
 			},
 		},
+		{
+			name:             "After cursor 9, Before cursor 19, First 3 (window larger than limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After:  lo.ToPtr(cursor.EncodeOffsetCursor(9)),
+				Before: lo.ToPtr(cursor.EncodeOffsetCursor(19)),
+				First:  lo.ToPtr(3),
+			},
+			expectedEdgesLen:   3,
+			expectedFirstKey:   10 + 1,
+			expectedLastKey:    12 + 1,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor:     lo.ToPtr(cursor.EncodeOffsetCursor(10)),
+				EndCursor:       lo.ToPtr(cursor.EncodeOffsetCursor(12)),
+			},
+		},
+		{
+			name:             "After cursor 9, Before cursor 19, Last 3 (window larger than limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After:  lo.ToPtr(cursor.EncodeOffsetCursor(9)),
+				Before: lo.ToPtr(cursor.EncodeOffsetCursor(19)),
+				Last:   lo.ToPtr(3),
+			},
+			expectedEdgesLen:   3,
+			expectedFirstKey:   16 + 1,
+			expectedLastKey:    18 + 1,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor:     lo.ToPtr(cursor.EncodeOffsetCursor(16)),
+				EndCursor:       lo.ToPtr(cursor.EncodeOffsetCursor(18)),
+			},
+		},
+		{
+			name:             "After cursor 9, Before cursor 19, First 9 (window equal to limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After:  lo.ToPtr(cursor.EncodeOffsetCursor(9)),
+				Before: lo.ToPtr(cursor.EncodeOffsetCursor(19)),
+				First:  lo.ToPtr(9),
+			},
+			expectedEdgesLen:   9,
+			expectedFirstKey:   10 + 1,
+			expectedLastKey:    18 + 1,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor:     lo.ToPtr(cursor.EncodeOffsetCursor(10)),
+				EndCursor:       lo.ToPtr(cursor.EncodeOffsetCursor(18)),
+			},
+		},
+		{
+			name:             "After cursor 9, Before cursor 19, Last 20 (window smaller than limit)",
+			defaultLimit:     10,
+			maxLimit:         20,
+			applyCursorsFunc: applyCursorsFunc,
+			paginateRequest: &relay.PaginateRequest[*User]{
+				After:  lo.ToPtr(cursor.EncodeOffsetCursor(9)),
+				Before: lo.ToPtr(cursor.EncodeOffsetCursor(19)),
+				Last:   lo.ToPtr(20),
+			},
+			expectedEdgesLen:   9,
+			expectedFirstKey:   10 + 1,
+			expectedLastKey:    18 + 1,
+			expectedTotalCount: lo.ToPtr(100),
+			expectedPageInfo: &relay.PageInfo{
+				HasNextPage:     true,
+				HasPreviousPage: true,
+				StartCursor:     lo.ToPtr(cursor.EncodeOffsetCursor(10)),
+				EndCursor:       lo.ToPtr(cursor.EncodeOffsetCursor(18)),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -648,3 +735,153 @@ func TestOffsetWithLastAndNilBeforeIfSkipTotalCount(t *testing.T) {
 	require.ErrorContains(t, err, "totalCount is required for fromEnd and nil before")
 	require.Nil(t, conn)
 }
+
+func TestWithMaxOffset(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		cursor.Base64(NewOffsetAdapter[*User](db, WithMaxOffset(50))),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+		relay.EnsureLimits[*User](10, 50),
+	)
+
+	// the row fetched to tell if there is a next page is at offset 50
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(50)})
+	require.NoError(t, err)
+	require.Len(t, conn.Nodes, 50)
+
+	// the end cursor is at offset 49
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(10)})
+	require.ErrorContains(t, err, "invalid after cursor: offset 60 exceeds the max offset 50, use keyset pagination for deep pages")
+
+	// offset plus limit
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(31)})
+	require.NoError(t, err)
+	after := conn.PageInfo.EndCursor
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: after, First: lo.ToPtr(19)})
+	require.NoError(t, err)
+	require.Equal(t, 32, conn.Nodes[0].ID)
+	require.Len(t, conn.Nodes, 19)
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: after, First: lo.ToPtr(20)})
+	require.ErrorContains(t, err, "invalid after cursor: offset 51 exceeds the max offset 50")
+	// before bounds the window
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: after, Before: conn.PageInfo.EndCursor, First: lo.ToPtr(30)})
+	require.NoError(t, err)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: lo.ToPtr(base64.RawURLEncoding.EncodeToString([]byte("59"))), Last: lo.ToPtr(10)})
+	require.ErrorContains(t, err, "invalid before cursor: offset 58 exceeds the max offset 50")
+
+	// the skip of last without before depends on the count
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)})
+	require.ErrorContains(t, err, "offset 99 exceeds the max offset 50")
+
+	_, err = relay.New(
+		cursor.Base64(NewOffsetAdapter[*User](db.Where("id <= ?", 55).Session(&gorm.Session{}), WithMaxOffset(50))),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+		relay.EnsureLimits[*User](10, 50),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)})
+	require.ErrorContains(t, err, "offset 54 exceeds the max offset 50")
+
+	conn, err = relay.New(
+		cursor.Base64(NewOffsetAdapter[*User](db.Where("id <= ?", 51).Session(&gorm.Session{}), WithMaxOffset(50))),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+		relay.EnsureLimits[*User](10, 50),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, 51, conn.Nodes[len(conn.Nodes)-1].ID)
+
+	// keyset ignores it
+	p = relay.New(
+		cursor.Base64(NewKeysetAdapter[*User](db, WithMaxOffset(50))),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+		relay.EnsureLimits[*User](10, 50),
+	)
+	after = nil
+	for i := 0; i < 3; i++ {
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: after, First: lo.ToPtr(30)})
+		require.NoError(t, err)
+		after = conn.PageInfo.EndCursor
+	}
+	require.Equal(t, 90, conn.Nodes[len(conn.Nodes)-1].ID)
+}
+
+func TestWithTotalCountFunc(t *testing.T) {
+	resetDB(t)
+
+	var calls int
+	exact := true
+	p := relay.New(
+		cursor.Base64(NewOffsetAdapter[*User](db, WithTotalCountFunc(func(ctx context.Context) (int, bool, error) {
+			calls++
+			return 42, exact, nil
+		}))),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+		relay.EnsureLimits[*User](10, 10),
+	)
+
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, lo.ToPtr(42), conn.TotalCount)
+	require.True(t, conn.TotalCountIsExact)
+	require.Equal(t, 1, calls)
+
+	// last without before is based on the supplied total
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, 33, conn.Nodes[0].ID)
+	require.Equal(t, 42, conn.Nodes[len(conn.Nodes)-1].ID)
+	require.Equal(t, 2, calls)
+
+	conn, err = p.Paginate(relay.WithSkip(context.Background(), relay.Skip{TotalCount: true}), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Nil(t, conn.TotalCount)
+	require.Equal(t, 2, calls)
+
+	// a stale total is reported as such
+	exact = false
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, lo.ToPtr(42), conn.TotalCount)
+	require.False(t, conn.TotalCountIsExact)
+
+	// but it does not decide which rows are fetched
+	staleCount := func(count int) Option {
+		return WithTotalCountFunc(func(ctx context.Context) (int, bool, error) {
+			return count, false, nil
+		})
+	}
+	for _, f := range []func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]{NewKeysetAdapter[*User], NewOffsetAdapter[*User]} {
+		for _, count := range []int{0, 5} {
+			stale := relay.New(
+				cursor.Base64(f(db, staleCount(count))),
+				relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+				relay.EnsureLimits[*User](10, 10),
+			)
+			conn, err = stale.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+			require.NoError(t, err)
+			require.Equal(t, lo.ToPtr(count), conn.TotalCount)
+			require.False(t, conn.TotalCountIsExact)
+			require.Len(t, conn.Nodes, 10)
+
+			conn, err = stale.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(10)})
+			require.NoError(t, err)
+			require.Equal(t, 11, conn.Nodes[0].ID)
+			require.True(t, conn.PageInfo.HasPreviousPage)
+			require.True(t, conn.PageInfo.HasNextPage)
+
+			// the last page is located by the exact count
+			conn, err = stale.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(3)})
+			require.NoError(t, err)
+			require.Equal(t, []int{98, 99, 100}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+			require.Equal(t, lo.ToPtr(100), conn.TotalCount)
+			require.True(t, conn.TotalCountIsExact)
+		}
+	}
+
+	_, err = relay.New(
+		NewOffsetAdapter[*User](db, WithTotalCountFunc(func(ctx context.Context) (int, bool, error) {
+			return 0, false, errors.New("cache unavailable")
+		})),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.ErrorContains(t, err, "total count: cache unavailable")
+}
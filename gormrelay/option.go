@@ -0,0 +1,224 @@
+package gormrelay
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type options struct {
+	caseInsensitiveFields bool
+	facetField            string
+	randomSeed            *string
+	primaryKey            []string
+	seqScanWarning        func(ctx context.Context, sql string)
+	statementTimeout      time.Duration
+	countTimeout          time.Duration
+	pinnedIDs             []any
+	sortKeys              map[string]string
+	countQuery            func(base *gorm.DB) *gorm.DB
+	distinctNodes         bool
+	maxOffset             int
+	deletedBreakdown      bool
+	totalCountFunc        func(ctx context.Context) (count int, exact bool, err error)
+	approximateCount      bool
+	selectFields          []string
+	distinctValues        map[string]int
+	autoTiebreaker        bool
+	concurrentCount       bool
+}
+
+type Option func(opts *options)
+
+func newOptions(opts ...Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCaseInsensitiveFields resolves orderBy fields case-insensitively against the schema,
+// so `id`, `Id` and `ID` all resolve to the `ID` field. orderBys resolving to the same field are rejected,
+// e.g. `id` from the client with `ID` appended by relay.EnsurePrimaryOrderBy, use WithPrimaryKey or
+// WithAutoTiebreaker instead, which append the primary key after the fields are resolved.
+func WithCaseInsensitiveFields() Option {
+	return func(opts *options) {
+		opts.caseInsensitiveFields = true
+	}
+}
+
+// WithFacets counts the rows per value of the field across the whole filtered set (ignoring pagination)
+// and attaches the result to Connection.Facets. NULL values are counted under the empty key.
+func WithFacets(field string) Option {
+	return func(opts *options) {
+		opts.facetField = field
+	}
+}
+
+// WithSeededRandomOrder orders rows by a hash of the primary key and the seed before any orderBys,
+// so the order looks random but is stable across pages of the same seed.
+// The primary key (see WithPrimaryKey) is appended to orderBys if missing. Only postgres and mysql are supported.
+func WithSeededRandomOrder(seed string) Option {
+	return func(opts *options) {
+		opts.randomSeed = &seed
+	}
+}
+
+// WithPrimaryKey overrides the primary key detected from the schema, the fields are appended to orderBys
+// as tiebreakers if missing, so they are also encoded into keyset cursors.
+// It is useful for views or tables whose unique key is not recognized by GORM.
+func WithPrimaryKey(fields ...string) Option {
+	return func(opts *options) {
+		opts.primaryKey = fields
+	}
+}
+
+// WithSeqScanWarning runs `EXPLAIN` (without ANALYZE) before finding the nodes,
+// and calls warn with the SQL if the plan contains a `Seq Scan`. Only postgres is supported.
+// Note the planner prefers sequential scans on small tables, so it is meant for staging-sized data.
+func WithSeqScanWarning(warn func(ctx context.Context, sql string)) Option {
+	return func(opts *options) {
+		opts.seqScanWarning = warn
+	}
+}
+
+// WithStatementTimeout makes postgres abort the queries of a pagination that run longer than d,
+// the error wraps relay.ErrQueryTimeout. It requires a transaction, the queries run in one with `SET LOCAL statement_timeout`,
+// if db is already in a transaction, the timeout applies to the rest of it.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(opts *options) {
+		opts.statementTimeout = d
+	}
+}
+
+// WithCountTimeout bounds the total count query by d, if it takes longer the page is still returned
+// but with a nil TotalCount, as if the total count were skipped.
+// Note offset pagination with last but without before requires the total count, so it fails in that case.
+func WithCountTimeout(d time.Duration) Option {
+	return func(opts *options) {
+		opts.countTimeout = d
+	}
+}
+
+// WithPinnedFirst puts the rows whose primary key is in ids before the others, then applies the orderBys.
+// The primary key (see WithPrimaryKey) is appended to orderBys if missing.
+func WithPinnedFirst(ids []any) Option {
+	return func(opts *options) {
+		opts.pinnedIDs = ids
+	}
+}
+
+// WithSortKey orders by the SQL expression expr whenever orderBys contain the field name,
+// e.g. a concatenation of several columns, so that a single value is compared and encoded into keyset cursors.
+// T must have the field name holding the value of expr, e.g. mapped to a generated column or selected with `AS`.
+func WithSortKey(name string, expr string) Option {
+	return func(opts *options) {
+		if opts.sortKeys == nil {
+			opts.sortKeys = make(map[string]string)
+		}
+		opts.sortKeys[name] = expr
+	}
+}
+
+// WithCountQuery replaces the query of TotalCount, base is the filtered query of the adapter
+// and the returned one is counted, e.g. to count via a summary table or a cheaper index.
+func WithCountQuery(countQuery func(base *gorm.DB) *gorm.DB) Option {
+	return func(opts *options) {
+		opts.countQuery = countQuery
+	}
+}
+
+// WithDistinctNodes fetches the rows with `SELECT DISTINCT` and counts the distinct primary keys (see WithPrimaryKey),
+// so that each node appears once when the query joins one-to-many relations.
+// It cannot be combined with ordering by expressions, i.e. WithPinnedFirst, WithSeededRandomOrder and folded orderBys.
+func WithDistinctNodes() Option {
+	return func(opts *options) {
+		opts.distinctNodes = true
+	}
+}
+
+// WithMaxOffset makes the offset adapter reject pages that would fetch rows beyond the offset n, i.e. offset plus limit,
+// including last without before, since the database still scans all the skipped rows, use keyset pagination for deep pages instead.
+// Pages continuing after and before cursors are rejected before counting.
+// The keyset adapter ignores it.
+func WithMaxOffset(n int) Option {
+	return func(opts *options) {
+		opts.maxOffset = n
+	}
+}
+
+// WithDeletedBreakdown additionally counts the soft-deleted rows matching the same conditions and attaches the result
+// to Connection.DeletedCount, e.g. for "42 active, 5 deleted". T must have a gorm.DeletedAt field.
+// It is skipped with the total count, and if db is unscoped since the total count includes the deleted rows then.
+// WithCountQuery does not apply to it.
+func WithDeletedBreakdown() Option {
+	return func(opts *options) {
+		opts.deletedBreakdown = true
+	}
+}
+
+// WithTotalCountFunc uses totalCount for TotalCount instead of the count query, e.g. a cached total
+// while paginating many pages of a large table. Unlike skipping the total count, the number is still returned.
+// totalCount reports whether the count is exact, Connection.TotalCountIsExact is false otherwise, e.g. for a stale cache.
+// An inexact count is only reported like WithApproximateCount, it never decides which rows are fetched.
+func WithTotalCountFunc(totalCount func(ctx context.Context) (count int, exact bool, err error)) Option {
+	return func(opts *options) {
+		opts.totalCountFunc = totalCount
+	}
+}
+
+// WithApproximateCount estimates TotalCount from the planner (i.e. pg_class.reltuples) instead of counting,
+// Connection.TotalCountIsExact is false then. It only applies to queries without conditions on postgres,
+// the others are still counted exactly, as are those with WithCountQuery or WithDistinctNodes.
+// The estimate is only reported, the rows are fetched as if the total count were skipped, and Last without
+// before is counted exactly since the offset adapter locates the last page by the count.
+func WithApproximateCount() Option {
+	return func(opts *options) {
+		opts.approximateCount = true
+	}
+}
+
+// WithSelectColumns selects only the columns of the fields instead of `SELECT *`, the fields of the orderBys are
+// added if missing so that the cursors can be encoded, as is the primary key with WithDistinctNodes.
+// The other fields of the nodes are left zero. It replaces the select of db, so db must not have one.
+func WithSelectColumns(fields []string) Option {
+	return func(opts *options) {
+		opts.selectFields = fields
+	}
+}
+
+// WithDistinctValues lists up to limit distinct values of the field across the whole filtered set (ignoring pagination)
+// and attaches them in ascending order to Connection.DistinctValues[field], e.g. to populate a filter dropdown.
+// It can be given for several fields.
+func WithDistinctValues(field string, limit int) Option {
+	if limit <= 0 {
+		panic("limit must be positive")
+	}
+	return func(opts *options) {
+		if opts.distinctValues == nil {
+			opts.distinctValues = make(map[string]int)
+		}
+		opts.distinctValues[field] = limit
+	}
+}
+
+// WithAutoTiebreaker appends the primary key (see WithPrimaryKey) to orderBys unless they already identify a row,
+// i.e. they cover the primary key or a unique index of not null columns, so that rows sharing the ordered values
+// are neither skipped nor repeated across pages. Folded orderBys and sort keys are not considered unique.
+func WithAutoTiebreaker() Option {
+	return func(opts *options) {
+		opts.autoTiebreaker = true
+	}
+}
+
+// WithConcurrentCount counts TotalCount in a goroutine while the adapter fetches the rows, so the latency is that of
+// the slower query instead of the sum of both. Each request then holds two connections of the pool at once.
+// The rows are counted first as usual if db is in a transaction (e.g. with WithStatementTimeout), since a transaction
+// cannot run queries concurrently, or for `last` without `before`, since the offset adapter locates the last page by the count.
+func WithConcurrentCount() Option {
+	return func(opts *options) {
+		opts.concurrentCount = true
+	}
+}
@@ -0,0 +1,260 @@
+package gormrelay
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// orderTerm is an item of the ORDER BY, and the value of Key in the keyset is compared against Column
+type orderTerm struct {
+	Key    string
+	Desc   bool
+	Column any             // clause.Column or an expression
+	Value  func(v any) any // converts the keyset value before comparing it with Column, nil means as it is
+}
+
+func buildOrderTerms(db *gorm.DB, s *schema.Schema, orderBys []relay.OrderBy, o *options) ([]*orderTerm, error) {
+	terms := make([]*orderTerm, 0, len(orderBys)+1)
+
+	if len(o.pinnedIDs) > 0 {
+		pk, err := primaryField(s, o)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, pinnedFirstTerm(pk, o.pinnedIDs))
+	}
+
+	if o.randomSeed != nil {
+		pk, err := primaryField(s, o)
+		if err != nil {
+			return nil, err
+		}
+		term, err := seededRandomTerm(db, pk, *o.randomSeed)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	aliases := selectAliases(db.Statement.Selects)
+	for _, orderBy := range orderBys {
+		field, ok := s.FieldsByName[orderBy.Field]
+		if !ok {
+			return nil, missingFieldError(s, orderBy.Field)
+		}
+		var column any = clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+		if expr, ok := o.sortKeys[orderBy.Field]; ok {
+			column = clause.Expr{SQL: "(" + expr + ")"}
+		} else if expr, ok := aliases[strings.ToLower(field.DBName)]; ok {
+			// an alias cannot be referenced in WHERE, so the selected expression is compared instead
+			column = clause.Expr{SQL: "(" + expr + ")"}
+		}
+		term := &orderTerm{
+			Key:    orderBy.Field,
+			Desc:   orderBy.Desc,
+			Column: column,
+		}
+		if orderBy.Fold {
+			// the keyset keeps the original value, LOWER of the database folds it too, since folding it in Go
+			// may disagree with the database, e.g. LOWER of sqlite only folds ASCII letters
+			term.Column = clause.Expr{SQL: "LOWER(?)", Vars: []any{column}}
+			term.Value = foldValue
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+func orderByClause(terms []*orderTerm, reverse bool) clause.OrderBy {
+	plain := true
+	for _, term := range terms {
+		if _, ok := term.Column.(clause.Column); !ok {
+			plain = false
+			break
+		}
+	}
+
+	if plain {
+		columns := make([]clause.OrderByColumn, 0, len(terms))
+		for _, term := range terms {
+			columns = append(columns, clause.OrderByColumn{
+				Column: term.Column.(clause.Column),
+				Desc:   term.Desc != reverse,
+			})
+		}
+		return clause.OrderBy{Columns: columns}
+	}
+
+	sqls := make([]string, 0, len(terms))
+	vars := make([]any, 0, len(terms))
+	for _, term := range terms {
+		if term.Desc != reverse {
+			sqls = append(sqls, "? DESC")
+		} else {
+			sqls = append(sqls, "?")
+		}
+		vars = append(vars, term.Column)
+	}
+	return clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(sqls, ","), Vars: vars, WithoutParentheses: true}}
+}
+
+func seededRandomSQL(db *gorm.DB) (string, error) {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "md5(CAST(? AS TEXT) || ?)", nil
+	case "mysql":
+		return "MD5(CONCAT(?, ?))", nil
+	default:
+		return "", errors.Errorf("seeded random order is not supported on %s", db.Dialector.Name())
+	}
+}
+
+// seededRandomTerm orders by the hash of the primary key and the seed,
+// the hash is derived from the primary key in the keyset, so the cursor does not need to carry it
+func seededRandomTerm(db *gorm.DB, pk *schema.Field, seed string) (*orderTerm, error) {
+	sql, err := seededRandomSQL(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderTerm{
+		Key:    pk.Name,
+		Column: clause.Expr{SQL: sql, Vars: []any{clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, seed}},
+		Value: func(v any) any {
+			return seededHash(v, seed)
+		},
+	}, nil
+}
+
+// foldValue applies LOWER to a string keyset value in SQL
+func foldValue(v any) any {
+	if s, ok := v.(string); ok {
+		return clause.Expr{SQL: "LOWER(?)", Vars: []any{s}}
+	}
+	return v
+}
+
+// seededHash computes the same hex digest as seededRandomSQL for a primary key value from the keyset
+func seededHash(v any, seed string) string {
+	sum := md5.Sum([]byte(keyText(v) + seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyText formats a primary key value the way the database casts it to text,
+// numbers decoded from a keyset without type information are float64
+func keyText(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// pinnedFirstTerm ranks the rows whose primary key is in ids before the others,
+// the rank is derived from the primary key in the keyset, so the cursor does not need to carry it
+func pinnedFirstTerm(pk *schema.Field, ids []any) *orderTerm {
+	pinned := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		pinned[keyText(id)] = true
+	}
+	return &orderTerm{
+		Key:    pk.Name,
+		Column: clause.Expr{SQL: "CASE WHEN ? IN ? THEN 0 ELSE 1 END", Vars: []any{clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, ids}},
+		Value: func(v any) any {
+			if pinned[keyText(v)] {
+				return 0
+			}
+			return 1
+		},
+	}
+}
+
+// selectClause selects the columns of o.selectFields and the orderBys, see WithSelectColumns
+func selectClause(db *gorm.DB, s *schema.Schema, o *options, orderBys []relay.OrderBy) (clause.Select, error) {
+	if len(db.Statement.Selects) > 0 {
+		return clause.Select{}, errors.New("WithSelectColumns cannot be combined with Select on db")
+	}
+
+	names := make([]string, 0, len(o.selectFields)+len(orderBys)+1)
+	names = append(names, o.selectFields...)
+	for _, orderBy := range orderBys {
+		names = append(names, orderBy.Field)
+	}
+	if o.distinctNodes {
+		fields, err := primaryFields(s, o)
+		if err != nil {
+			return clause.Select{}, err
+		}
+		for _, field := range fields {
+			names = append(names, field.Name)
+		}
+	}
+
+	sel := clause.Select{Distinct: o.distinctNodes}
+	for _, name := range lo.Uniq(names) {
+		field, ok := s.FieldsByName[name]
+		if !ok {
+			return clause.Select{}, missingFieldError(s, name)
+		}
+		if expr, ok := o.sortKeys[name]; ok {
+			sel.Columns = append(sel.Columns, clause.Column{Name: "(" + expr + ") AS " + db.Statement.Quote(field.DBName), Raw: true})
+			continue
+		}
+		sel.Columns = append(sel.Columns, clause.Column{Table: clause.CurrentTable, Name: field.DBName})
+	}
+	return sel, nil
+}
+
+var selectAliasRegexp = regexp.MustCompile("(?is)^(.+?)\\s+AS\\s+[\"'`]?(\\w+)[\"'`]?$")
+
+// selectAliases maps the lowercased aliases of the selected columns to their expressions,
+// e.g. `u.*, c.name AS company_name` results in {"company_name": "c.name"}
+func selectAliases(selects []string) map[string]string {
+	aliases := make(map[string]string)
+	for _, sel := range selects {
+		for _, item := range splitTopLevel(sel, ',') {
+			m := selectAliasRegexp.FindStringSubmatch(strings.TrimSpace(item))
+			if m == nil {
+				continue
+			}
+			aliases[strings.ToLower(m[2])] = strings.TrimSpace(m[1])
+		}
+	}
+	return aliases
+}
+
+// splitTopLevel splits s by sep outside of parentheses and quotes
+func splitTopLevel(s string, sep rune) []string {
+	var items []string
+	var depth int
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == sep && depth == 0:
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
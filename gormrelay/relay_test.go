@@ -3,10 +3,17 @@ package gormrelay
 import (
 	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
@@ -70,10 +77,75 @@ func TestUnexpectOrderBys(t *testing.T) {
 	require.Nil(t, conn)
 }
 
+func TestEnsureMaxOrderByFields(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			f(db),
+			relay.EnsureMaxOrderByFields[*User](2),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(10),
+			OrderBys: []relay.OrderBy{{Field: "Age", Desc: true}},
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 10)
+
+		// the primary order by is merged before counting
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(10),
+			OrderBys: []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "Name"}},
+		})
+		require.ErrorContains(t, err, "too many orderBy fields: 3 exceeds max 2")
+		require.Nil(t, conn)
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(10),
+			OrderBys: []relay.OrderBy{{Field: "Name"}, {Field: "ID"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 10)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestUnderlyingError(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			f(db.Where("unknown_column = ?", 1).Session(&gorm.Session{})),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		for _, ctx := range []context.Context{
+			context.Background(),
+			relay.WithSkip(context.Background(), relay.Skip{TotalCount: true}),
+		} {
+			conn, err := p.Paginate(ctx, &relay.PaginateRequest[*User]{
+				First: lo.ToPtr(10),
+			})
+			require.Nil(t, conn)
+			var pgErr *pgconn.PgError
+			require.ErrorAs(t, err, &pgErr)
+			require.Equal(t, "42703", pgErr.Code) // undefined_column
+			require.Equal(t, pgErr, errors.Cause(err))
+		}
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
 func TestContext(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 		{
 			p := relay.New(
 				f(db),
@@ -114,7 +186,7 @@ func TestContext(t *testing.T) {
 func TestSkip(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 		p := relay.New(
 			f(db),
 			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
@@ -257,7 +329,7 @@ func TestSkip(t *testing.T) {
 func TestGenericTypeAny(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[any]) {
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[any]) {
 		t.Run("Correct", func(t *testing.T) {
 			p := relay.New(
 				func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[any], error) {
@@ -336,7 +408,7 @@ func TestTotalCountZero(t *testing.T) {
 	resetDB(t)
 	require.NoError(t, db.Exec("DELETE FROM users").Error)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 		p := relay.New(
 			f(db),
 			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
@@ -355,6 +427,96 @@ func TestTotalCountZero(t *testing.T) {
 	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
 }
 
+func TestStableEmptyPageInfo(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DELETE FROM users").Error)
+
+	testCase := func(t *testing.T, after string, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		// records whether the find runs
+		var found bool
+		p := relay.New(
+			f(db.Scopes(func(tx *gorm.DB) *gorm.DB {
+				if _, ok := tx.Statement.Dest.(*int64); !ok {
+					found = true
+				}
+				return tx
+			}).Session(&gorm.Session{})),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		t.Run("TotalCountZero", func(t *testing.T) {
+			conn, err := p.Paginate(relay.WithStableEmptyPageInfo(context.Background()), &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(after),
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Equal(t, lo.ToPtr(0), conn.TotalCount)
+			require.Len(t, conn.Edges, 0)
+			require.Equal(t, &relay.PageInfo{}, conn.PageInfo)
+			require.False(t, found)
+		})
+		t.Run("SkipTotalCount", func(t *testing.T) {
+			ctx := relay.WithSkip(context.Background(), relay.Skip{
+				TotalCount: true,
+			})
+			conn, err := p.Paginate(relay.WithStableEmptyPageInfo(ctx), &relay.PaginateRequest[*User]{
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Nil(t, conn.TotalCount)
+			require.Len(t, conn.Edges, 0)
+			require.Equal(t, &relay.PageInfo{}, conn.PageInfo)
+
+			conn, err = p.Paginate(relay.WithStableEmptyPageInfo(ctx), &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(after),
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Nil(t, conn.TotalCount)
+			require.Len(t, conn.Edges, 0)
+			require.Equal(t, &relay.PageInfo{}, conn.PageInfo)
+		})
+	}
+
+	t.Run("keyset", func(t *testing.T) {
+		testCase(t, mustEncodeKeysetCursor(&User{ID: 1}, []string{"ID"}), NewKeysetAdapter)
+	})
+	t.Run("offset", func(t *testing.T) {
+		testCase(t, cursor.EncodeOffsetCursor(1), NewOffsetAdapter)
+	})
+
+	// an after past the end fetches no rows
+	resetDB(t)
+	ctx := relay.WithStableEmptyPageInfo(relay.WithSkip(context.Background(), relay.Skip{TotalCount: true}))
+	for after, f := range map[string]func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]{
+		mustEncodeKeysetCursor(&User{ID: 100}, []string{"ID"}): NewKeysetAdapter,
+		cursor.EncodeOffsetCursor(200):                         NewOffsetAdapter,
+	} {
+		p := relay.New(
+			f(db),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		conn, err := p.Paginate(ctx, &relay.PaginateRequest[*User]{
+			After: lo.ToPtr(after),
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Nil(t, conn.TotalCount)
+		require.Len(t, conn.Edges, 0)
+		require.Equal(t, &relay.PageInfo{}, conn.PageInfo)
+
+		// without WithStableEmptyPageInfo, after is still assumed to exist
+		conn, err = p.Paginate(relay.WithSkip(context.Background(), relay.Skip{TotalCount: true}), &relay.PaginateRequest[*User]{
+			After: lo.ToPtr(after),
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 0)
+		require.Equal(t, &relay.PageInfo{HasPreviousPage: true}, conn.PageInfo)
+	}
+}
+
 func generateGCMKey(length int) ([]byte, error) {
 	key := make([]byte, length)
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
@@ -366,7 +528,7 @@ func generateGCMKey(length int) ([]byte, error) {
 func TestCursorMiddleware(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 		p := relay.New(
 			f(db),
 			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
@@ -417,13 +579,13 @@ func TestCursorMiddleware(t *testing.T) {
 
 	t.Run("Base64", func(t *testing.T) {
 		t.Run("keyset", func(t *testing.T) {
-			testCase(t, func(db *gorm.DB) relay.ApplyCursorsFunc[*User] {
-				return cursor.Base64[*User](NewKeysetAdapter[*User](db))
+			testCase(t, func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User] {
+				return cursor.Base64[*User](NewKeysetAdapter[*User](db, opts...))
 			})
 		})
 		t.Run("keyset", func(t *testing.T) {
-			testCase(t, func(db *gorm.DB) relay.ApplyCursorsFunc[*User] {
-				return cursor.Base64[*User](NewOffsetAdapter[*User](db))
+			testCase(t, func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User] {
+				return cursor.Base64[*User](NewOffsetAdapter[*User](db, opts...))
 			})
 		})
 	})
@@ -436,20 +598,75 @@ func TestCursorMiddleware(t *testing.T) {
 		require.NoError(t, err)
 
 		t.Run("keyset", func(t *testing.T) {
-			testCase(t, func(db *gorm.DB) relay.ApplyCursorsFunc[*User] {
-				return cursor.GCM[*User](gcm)(NewKeysetAdapter[*User](db))
+			testCase(t, func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User] {
+				return cursor.GCM[*User](gcm)(NewKeysetAdapter[*User](db, opts...))
+			})
+		})
+
+		t.Run("offset", func(t *testing.T) {
+			testCase(t, func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User] {
+				return cursor.GCM[*User](gcm)(NewOffsetAdapter[*User](db, opts...))
+			})
+		})
+	})
+
+	t.Run("Signed", func(t *testing.T) {
+		key, err := generateGCMKey(32)
+		require.NoError(t, err)
+
+		t.Run("keyset", func(t *testing.T) {
+			testCase(t, func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User] {
+				return cursor.Signed[*User](key)(NewKeysetAdapter[*User](db, opts...))
 			})
 		})
 
 		t.Run("offset", func(t *testing.T) {
-			testCase(t, func(db *gorm.DB) relay.ApplyCursorsFunc[*User] {
-				return cursor.GCM[*User](gcm)(NewOffsetAdapter[*User](db))
+			testCase(t, func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User] {
+				return cursor.Signed[*User](key)(NewOffsetAdapter[*User](db, opts...))
+			})
+		})
+
+		t.Run("tampered", func(t *testing.T) {
+			p := relay.New(
+				cursor.Signed[*User](key)(NewKeysetAdapter[*User](db)),
+				relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+				relay.EnsureLimits[*User](10, 10),
+			)
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				First: lo.ToPtr(5),
+			})
+			require.NoError(t, err)
+
+			payload, signature, ok := strings.Cut(*conn.PageInfo.EndCursor, ".")
+			require.True(t, ok)
+			raw, err := base64.RawURLEncoding.DecodeString(payload)
+			require.NoError(t, err)
+			require.Equal(t, `{"ID":5}`, string(raw))
+
+			tampered := base64.RawURLEncoding.EncodeToString([]byte(`{"ID":50}`)) + "." + signature
+			conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				First: lo.ToPtr(5),
+				After: &tampered,
+			})
+			require.ErrorContains(t, err, "invalid after cursor: cursor signature mismatch")
+			require.ErrorIs(t, err, cursor.ErrCursorSignatureMismatch)
+			require.Nil(t, conn)
+
+			otherKey, err := generateGCMKey(32)
+			require.NoError(t, err)
+			_, err = relay.New(
+				cursor.Signed[*User](otherKey)(NewKeysetAdapter[*User](db)),
+				relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				First:  lo.ToPtr(5),
+				Before: lo.ToPtr(payload + "." + signature),
 			})
+			require.ErrorIs(t, err, cursor.ErrCursorSignatureMismatch)
 		})
 	})
 
 	t.Run("MockError", func(t *testing.T) {
-		testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+		testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 			p := relay.New(
 				func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User] {
 					return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
@@ -493,7 +710,7 @@ func TestAppendCursorMiddleware(t *testing.T) {
 
 	gcmMiddleware := cursor.GCM[*User](gcm)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 		p := relay.New(
 			f(db),
 			relay.AppendCursorMiddleware(gcmMiddleware),
@@ -533,7 +750,7 @@ func TestAppendCursorMiddleware(t *testing.T) {
 func TestWithNodeProcessor(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 		p := relay.New(
 			f(db),
 			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
@@ -591,7 +808,7 @@ func TestWithNodeProcessor(t *testing.T) {
 func TestOrderBys(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, cursorTest bool, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, cursorTest bool, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
 		p := relay.New(
 			f(db),
 			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
@@ -701,7 +918,7 @@ func TestOrderBys(t *testing.T) {
 						{Field: "NameX", Desc: true},
 					},
 				})
-				require.ErrorContains(t, err, `find: missing field "NameX" in schema`)
+				require.ErrorContains(t, err, `find: missing field "NameX" in schema, did you mean "Name"?`)
 				require.Nil(t, conn)
 			})
 		}
@@ -757,3 +974,1530 @@ func TestAppendPrimaryOrderBy(t *testing.T) {
 		}),
 	)
 }
+
+func TestCaseInsensitiveFields(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			f(db, WithCaseInsensitiveFields(), WithPrimaryKey("ID")),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{
+				{Field: "age", Desc: false},
+				{Field: "id", Desc: true},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Equal(t, 99+1, conn.Edges[0].Node.ID)
+		require.Equal(t, 95+1, conn.Edges[len(conn.Edges)-1].Node.ID)
+
+		// next page
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+			After: conn.PageInfo.EndCursor,
+			OrderBys: []relay.OrderBy{
+				{Field: "Age", Desc: false},
+				{Field: "Id", Desc: true},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Equal(t, 94+1, conn.Edges[0].Node.ID)
+		require.Equal(t, 90+1, conn.Edges[len(conn.Edges)-1].Node.ID)
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{
+				{Field: "unexpect", Desc: false},
+			},
+		})
+		require.ErrorContains(t, err, `missing field "unexpect" in schema`)
+		require.Nil(t, conn)
+
+		// fields resolving to the same field are ambiguous
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{
+				{Field: "id", Desc: true},
+				{Field: "Id", Desc: false},
+			},
+		})
+		require.ErrorContains(t, err, `order by fields "id" and "Id" resolve to the same field "ID"`)
+		require.Nil(t, conn)
+
+		conn, err = relay.New(
+			f(db, WithCaseInsensitiveFields()),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{{Field: "id", Desc: true}},
+		})
+		require.ErrorContains(t, err, `order by fields "id" and "ID" resolve to the same field "ID"`)
+		require.Nil(t, conn)
+
+		// without the option
+		conn, err = relay.New(
+			f(db),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{
+				{Field: "id", Desc: true},
+			},
+		})
+		require.ErrorContains(t, err, `missing field "id" in schema, did you mean "ID"?`)
+		require.Nil(t, conn)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestFacets(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET age = 18 WHERE id <= 30").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			f(db.Where("id <= ?", 40).Session(&gorm.Session{}), WithFacets("Age")),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Equal(t, lo.ToPtr(40), conn.TotalCount)
+		require.Len(t, conn.Facets, 11)
+		require.Equal(t, 30, conn.Facets["18"])
+		require.Equal(t, 1, conn.Facets["70"])
+		require.Equal(t, 1, conn.Facets["61"])
+
+		conn, err = relay.New(
+			f(db, WithFacets("Unexpect")),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.ErrorContains(t, err, `missing field "Unexpect" in schema`)
+		require.Nil(t, conn)
+
+		conn, err = relay.New(
+			f(db),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Nil(t, conn.Facets)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestDistinctValues(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET age = 18 WHERE id <= 30").Error)
+	require.NoError(t, db.Exec("UPDATE users SET name = 'dup' WHERE id <= 10").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			f(db.Where("id <= ?", 40).Session(&gorm.Session{}), WithDistinctValues("Age", 3), WithDistinctValues("Name", 100)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Equal(t, lo.ToPtr(40), conn.TotalCount)
+		require.Len(t, conn.DistinctValues, 2)
+		require.Equal(t, []any{int64(18), int64(61), int64(62)}, conn.DistinctValues["Age"])
+		require.Len(t, conn.DistinctValues["Name"], 31)
+		require.Equal(t, "dup", conn.DistinctValues["Name"][0])
+
+		conn, err = relay.New(
+			f(db, WithDistinctValues("Unexpect", 10)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.ErrorContains(t, err, `missing field "Unexpect" in schema`)
+		require.Nil(t, conn)
+
+		conn, err = relay.New(
+			f(db),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Nil(t, conn.DistinctValues)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+
+	require.PanicsWithValue(t, "limit must be positive", func() { WithDistinctValues("Age", 0) })
+}
+
+func TestSeededRandomOrder(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		collect := func(seed string) []int {
+			p := relay.New(
+				cursor.Base64(f(db, WithSeededRandomOrder(seed))),
+				relay.EnsureLimits[*User](10, 10),
+			)
+			var ids []int
+			var after *string
+			for {
+				conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+					After: after,
+					First: lo.ToPtr(10),
+				})
+				require.NoError(t, err)
+				for _, edge := range conn.Edges {
+					ids = append(ids, edge.Node.ID)
+				}
+				if !conn.PageInfo.HasNextPage {
+					break
+				}
+				after = conn.PageInfo.EndCursor
+			}
+			return ids
+		}
+
+		ids := collect("foo")
+		require.Len(t, ids, 100)
+		require.Len(t, lo.Uniq(ids), 100)
+		require.False(t, slices.IsSorted(ids))
+		require.Equal(t, ids, collect("foo"))
+		require.NotEqual(t, ids, collect("bar"))
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestPinnedFirst(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(db, WithPinnedFirst([]any{50, 7, 99}))),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		var ids []int
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After: after,
+				First: lo.ToPtr(2),
+			})
+			require.NoError(t, err)
+			for _, edge := range conn.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Len(t, ids, 100)
+		require.Len(t, lo.Uniq(ids), 100)
+		require.Equal(t, []int{7, 50, 99, 1, 2}, ids[:5])
+		require.True(t, slices.IsSorted(ids[3:]))
+
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Last: lo.ToPtr(3),
+			OrderBys: []relay.OrderBy{
+				{Field: "ID", Desc: true},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{3, 2, 1}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Before: conn.PageInfo.StartCursor,
+			Last:   lo.ToPtr(4),
+			OrderBys: []relay.OrderBy{
+				{Field: "ID", Desc: true},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{8, 6, 5, 4}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(4),
+			OrderBys: []relay.OrderBy{
+				{Field: "ID", Desc: true},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{99, 50, 7, 100}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestEffectiveLimit(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		cursor.Base64(NewKeysetAdapter[*User](db)),
+		relay.EnsureLimits[*User](10, 20),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+	)
+
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(50)})
+	require.NoError(t, err)
+	require.Equal(t, 20, conn.EffectiveLimit)
+	require.Len(t, conn.Nodes, 20)
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+	require.NoError(t, err)
+	require.Equal(t, 10, conn.EffectiveLimit)
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, 5, conn.EffectiveLimit)
+	require.Len(t, conn.Nodes, 5)
+}
+
+func TestEnsureLimitsFunc(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		cursor.Base64(NewKeysetAdapter[*User](db)),
+		relay.EnsureLimitsFunc(func(req *relay.PaginateRequest[*User]) (int, int) {
+			if req.After == nil && req.Before == nil {
+				return 20, 50
+			}
+			return 10, 20
+		}),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+	)
+
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+	require.NoError(t, err)
+	require.Len(t, conn.Nodes, 20)
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(100)})
+	require.NoError(t, err)
+	require.Len(t, conn.Nodes, 50)
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: conn.PageInfo.EndCursor})
+	require.NoError(t, err)
+	require.Len(t, conn.Nodes, 10)
+	require.Equal(t, 51, conn.Nodes[0].ID)
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(100)})
+	require.NoError(t, err)
+	require.Len(t, conn.Nodes, 20)
+
+	p = relay.New(
+		cursor.Base64(NewKeysetAdapter[*User](db)),
+		relay.EnsureLimitsFunc(func(req *relay.PaginateRequest[*User]) (int, int) {
+			return 10, 5
+		}),
+	)
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+	require.ErrorContains(t, err, "maxLimit must be greater than or equal to defaultLimit")
+}
+
+type UserSortKey struct {
+	ID      int
+	Name    string
+	Age     int
+	SortKey int
+}
+
+func (UserSortKey) TableName() string { return "user_sort_keys" }
+
+func TestWithSortKey(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET age = id % 10").Error)
+	require.NoError(t, db.Exec("CREATE VIEW user_sort_keys AS SELECT *, age * 1000 + id AS sort_key FROM users").Error)
+	t.Cleanup(func() {
+		require.NoError(t, db.Exec("DROP VIEW user_sort_keys").Error)
+	})
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*UserSortKey]) {
+		p := relay.New(
+			cursor.Base64(f(db, WithSortKey("SortKey", "age * 1000 + id"))),
+			relay.EnsureLimits[*UserSortKey](10, 10),
+		)
+		var ids []int
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*UserSortKey]{
+				After: after,
+				First: lo.ToPtr(7),
+				OrderBys: []relay.OrderBy{
+					{Field: "SortKey", Desc: true},
+				},
+			})
+			require.NoError(t, err)
+			for _, edge := range conn.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Len(t, ids, 100)
+		require.Equal(t, []int{99, 89, 79}, ids[:3])
+		require.Equal(t, []int{20, 10}, ids[98:])
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+type UserCode struct {
+	Code string
+	Name string
+	Age  int
+}
+
+func (UserCode) TableName() string { return "user_codes" }
+
+func TestWithPrimaryKey(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET age = id % 10").Error)
+	require.NoError(t, db.Exec("CREATE VIEW user_codes AS SELECT 'code' || id AS code, name, age FROM users").Error)
+	t.Cleanup(func() {
+		require.NoError(t, db.Exec("DROP VIEW user_codes").Error)
+	})
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*UserCode]) {
+		p := relay.New(
+			cursor.Base64(f(db, WithPrimaryKey("Code"))),
+			relay.EnsureLimits[*UserCode](10, 10),
+		)
+		codes := map[string]bool{}
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*UserCode]{
+				After:    after,
+				First:    lo.ToPtr(10),
+				OrderBys: []relay.OrderBy{{Field: "Age", Desc: true}},
+			})
+			require.NoError(t, err)
+			for _, edge := range conn.Edges {
+				codes[edge.Node.Code] = true
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Len(t, codes, 100)
+
+		conn, err := relay.New(
+			f(db, WithPrimaryKey("Unexpect")),
+			relay.EnsureLimits[*UserCode](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*UserCode]{
+			First: lo.ToPtr(10),
+		})
+		require.ErrorContains(t, err, `missing field "Unexpect" in schema`)
+		require.Nil(t, conn)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+type Member struct {
+	ID     int     `gorm:"primarykey;not null;"`
+	Email  string  `gorm:"uniqueIndex;not null;"`
+	Nick   *string `gorm:"unique;"`
+	OrgID  int     `gorm:"uniqueIndex:idx_org_number;not null;"`
+	Number int     `gorm:"uniqueIndex:idx_org_number;not null;"`
+}
+
+func TestWithAutoTiebreaker(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET age = id % 10").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(db, WithAutoTiebreaker())),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		ids := map[int]bool{}
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After:    after,
+				First:    lo.ToPtr(10),
+				OrderBys: []relay.OrderBy{{Field: "Age", Desc: true}},
+			})
+			require.NoError(t, err)
+			for _, edge := range conn.Edges {
+				ids[edge.Node.ID] = true
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Len(t, ids, 100)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+
+	for _, tc := range []struct {
+		orderBys []relay.OrderBy
+		expected []relay.OrderBy
+	}{
+		{
+			orderBys: nil,
+			expected: []relay.OrderBy{{Field: "ID"}},
+		},
+		{
+			orderBys: []relay.OrderBy{{Field: "ID", Desc: true}},
+			expected: []relay.OrderBy{{Field: "ID", Desc: true}},
+		},
+		{
+			orderBys: []relay.OrderBy{{Field: "Email", Desc: true}},
+			expected: []relay.OrderBy{{Field: "Email", Desc: true}},
+		},
+		{
+			orderBys: []relay.OrderBy{{Field: "Email", Fold: true}},
+			expected: []relay.OrderBy{{Field: "Email", Fold: true}, {Field: "ID"}},
+		},
+		{
+			// unique but nullable
+			orderBys: []relay.OrderBy{{Field: "Nick"}},
+			expected: []relay.OrderBy{{Field: "Nick"}, {Field: "ID"}},
+		},
+		{
+			orderBys: []relay.OrderBy{{Field: "OrgID"}},
+			expected: []relay.OrderBy{{Field: "OrgID"}, {Field: "ID"}},
+		},
+		{
+			orderBys: []relay.OrderBy{{Field: "Number"}, {Field: "OrgID"}},
+			expected: []relay.OrderBy{{Field: "Number"}, {Field: "OrgID"}},
+		},
+	} {
+		orderBys, err := prepareOrderBys[*Member](db, newOptions(WithAutoTiebreaker()), tc.orderBys)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, orderBys)
+	}
+
+	orderBys, err := prepareOrderBys[*Member](db, newOptions(WithAutoTiebreaker(), WithSortKey("Email", "lower(email)")), []relay.OrderBy{{Field: "Email"}})
+	require.NoError(t, err)
+	require.Equal(t, []relay.OrderBy{{Field: "Email"}, {Field: "ID"}}, orderBys)
+}
+
+func TestEnsurePrimaryOrderByFromSchema(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET age = id % 10").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(db)),
+			EnsurePrimaryOrderByFromSchema[*User](db),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		var ids []int
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After:    after,
+				First:    lo.ToPtr(10),
+				OrderBys: []relay.OrderBy{{Field: "Age", Desc: true}},
+			})
+			require.NoError(t, err)
+			for _, edge := range conn.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Len(t, ids, 100)
+		require.Equal(t, []int{9, 19, 29}, ids[:3])
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+
+	conn, err := relay.New(
+		NewKeysetAdapter[*UserCode](db),
+		EnsurePrimaryOrderByFromSchema[*UserCode](db),
+	).Paginate(context.Background(), &relay.PaginateRequest[*UserCode]{
+		First: lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, "missing primary key, use WithPrimaryKey to specify it")
+	require.Nil(t, conn)
+}
+
+func TestCTEAdapter(t *testing.T) {
+	resetDB(t)
+
+	cteSQL := `WITH RECURSIVE tree AS (
+		SELECT id, name, age FROM users WHERE id = ?
+		UNION ALL
+		SELECT u.id, u.name, u.age FROM users u JOIN tree ON u.id = tree.id + 1 WHERE u.id <= ?
+	) SELECT * FROM tree`
+
+	p := relay.New(
+		cursor.Base64(NewCTEAdapter[*User](db, cteSQL, []any{11, 40}, "id")),
+		relay.EnsureLimits[*User](10, 10),
+	)
+	var ids []int
+	var after *string
+	for {
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After:    after,
+			First:    lo.ToPtr(7),
+			OrderBys: []relay.OrderBy{{Field: "Age", Desc: false}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 30, *conn.TotalCount)
+		for _, edge := range conn.Edges {
+			ids = append(ids, edge.Node.ID)
+		}
+		if !conn.PageInfo.HasNextPage {
+			break
+		}
+		after = conn.PageInfo.EndCursor
+	}
+	require.Equal(t, lo.RangeWithSteps(40, 10, -1), ids)
+
+	conn, err := relay.New(
+		NewCTEAdapter[*User](db, cteSQL, []any{11, 40}, "code"),
+		relay.EnsureLimits[*User](10, 10),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, `missing column "code" in schema`)
+	require.Nil(t, conn)
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"ID", "Name", "Age", "CreatedAt"}
+	require.Equal(t, "ID", suggest("id", candidates))
+	require.Equal(t, "Age", suggest("Aeg", candidates))
+	require.Equal(t, "CreatedAt", suggest("CreateAt", candidates))
+	require.Equal(t, "", suggest("Unexpect", candidates))
+	require.Equal(t, "", suggest("", candidates))
+}
+
+func TestSeqScanWarning(t *testing.T) {
+	resetDB(t)
+
+	type ctxKey struct{}
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		var sqls []string
+		p := relay.New(
+			f(db, WithSeqScanWarning(func(ctx context.Context, sql string) {
+				require.Equal(t, "value", ctx.Value(ctxKey{}))
+				sqls = append(sqls, sql)
+			})),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+		conn, err := p.Paginate(ctx, &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{{Field: "Name", Desc: false}},
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Len(t, sqls, 1)
+		require.Contains(t, sqls[0], `ORDER BY "users"."name" LIMIT 6`)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestByteBudget(t *testing.T) {
+	resetDB(t)
+
+	sizeOf := func(user *User) int { return len(user.Name) }
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(db)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+			// name0 ~ name9 are 5 bytes, name10 ~ name99 are 6 bytes
+			relay.WithByteBudget(32, sizeOf),
+		)
+
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 6)
+		require.Equal(t, 100, *conn.TotalCount)
+		require.True(t, conn.PageInfo.HasNextPage)
+		require.Equal(t, conn.Edges[5].Cursor, *conn.PageInfo.EndCursor)
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: conn.PageInfo.EndCursor,
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 6)
+		require.Equal(t, 7, conn.Edges[0].Node.ID)
+		require.Equal(t, 12, conn.Edges[5].Node.ID)
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(3),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 3)
+		require.Equal(t, conn.Edges[2].Cursor, *conn.PageInfo.EndCursor)
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Last: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Equal(t, 96, conn.Edges[0].Node.ID)
+		require.True(t, conn.PageInfo.HasPreviousPage)
+		require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+
+		conn, err = relay.New(
+			cursor.Base64(f(db)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+			relay.WithByteBudget(1, sizeOf),
+		).Paginate(relay.WithSkip(context.Background(), relay.Skip{Edges: true}), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Nil(t, conn.Edges)
+		require.Len(t, conn.Nodes, 1)
+		require.True(t, conn.PageInfo.HasNextPage)
+		require.NotNil(t, conn.PageInfo.EndCursor)
+
+		// the limit must be resolved before the budget, it is never bypassed
+		_, err = relay.New(
+			cursor.Base64(f(db)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.WithByteBudget(32, sizeOf),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+		require.ErrorContains(t, err, "first or last must be set for the byte budget")
+
+		// the page is fetched in chunks, sized by the average node size, and stops once the budget is hit
+		var limits []int
+		var counted int
+		p = relay.New(
+			func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
+				limits = append(limits, req.Limit)
+				rsp, err := cursor.Base64(f(db, WithFacets("Age")))(ctx, req)
+				if err == nil && (rsp.TotalCount != nil || rsp.Facets != nil) {
+					counted++
+				}
+				return rsp, err
+			},
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](50, 50),
+			relay.WithByteBudget(100, sizeOf),
+		)
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(50),
+		})
+		require.NoError(t, err)
+		// 10 rows of 5 bytes, then 11 rows are estimated to fit the rest, 8 rows of 6 bytes do,
+		// the limit of the adapter includes the row telling if there is a next page
+		require.Equal(t, []int{11, 12}, limits)
+		require.Equal(t, 1, counted)
+		require.Equal(t, 100, *conn.TotalCount)
+		require.Len(t, conn.Facets, 100)
+		require.Equal(t, 50, conn.EffectiveLimit)
+		require.Equal(t, lo.RangeFrom(1, 18), lo.Map(conn.Nodes, func(user *User, _ int) int { return user.ID }))
+		require.False(t, conn.PageInfo.HasPreviousPage)
+		require.True(t, conn.PageInfo.HasNextPage)
+		require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+		require.Equal(t, conn.Edges[17].Cursor, *conn.PageInfo.EndCursor)
+
+		limits = nil
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: conn.PageInfo.EndCursor,
+			First: lo.ToPtr(50),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{11, 8}, limits)
+		require.Equal(t, lo.RangeFrom(19, 16), lo.Map(conn.Nodes, func(user *User, _ int) int { return user.ID }))
+		require.True(t, conn.PageInfo.HasPreviousPage)
+		require.True(t, conn.PageInfo.HasNextPage)
+
+		limits = nil
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Last: lo.ToPtr(50),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{11, 8}, limits)
+		require.Equal(t, lo.RangeFrom(85, 16), lo.Map(conn.Nodes, func(user *User, _ int) int { return user.ID }))
+		require.True(t, conn.PageInfo.HasPreviousPage)
+		require.False(t, conn.PageInfo.HasNextPage)
+		require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+
+		// the rows run out before the budget
+		conn, err = relay.New(
+			cursor.Base64(f(db)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](95, 95),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(95),
+		})
+		require.NoError(t, err)
+		limits = nil
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: conn.PageInfo.EndCursor,
+			First: lo.ToPtr(50),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{11}, limits)
+		require.Len(t, conn.Nodes, 5)
+		require.False(t, conn.PageInfo.HasNextPage)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestStatementTimeout(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		slowDB := db.Where("id > (SELECT 0 FROM pg_sleep(0.2))").Session(&gorm.Session{})
+
+		conn, err := relay.New(
+			f(slowDB, WithStatementTimeout(50*time.Millisecond)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.ErrorIs(t, err, relay.ErrQueryTimeout)
+		var pgErr *pgconn.PgError
+		require.ErrorAs(t, err, &pgErr)
+		require.Equal(t, "57014", pgErr.Code) // query_canceled
+		require.Nil(t, conn)
+
+		conn, err = relay.New(
+			f(slowDB, WithStatementTimeout(10*time.Second)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Equal(t, 100, *conn.TotalCount)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestCountTimeout(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		newPagination := func(d time.Duration) relay.Pagination[*User] {
+			return relay.New(
+				f(db, WithCountTimeout(d)),
+				relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+				relay.EnsureLimits[*User](10, 10),
+			)
+		}
+
+		conn, err := newPagination(time.Nanosecond).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Nil(t, conn.TotalCount)
+		require.False(t, conn.TotalCountIsExact)
+		require.True(t, conn.PageInfo.HasNextPage)
+
+		conn, err = newPagination(10*time.Second).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Len(t, conn.Edges, 5)
+		require.Equal(t, lo.ToPtr(100), conn.TotalCount)
+		require.True(t, conn.TotalCountIsExact)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		conn, err = newPagination(10*time.Second).Paginate(ctx, &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(5),
+		})
+		require.ErrorContains(t, err, "context canceled")
+		require.Nil(t, conn)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestWithCountQuery(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		var bases []string
+		countQuery := WithCountQuery(func(base *gorm.DB) *gorm.DB {
+			bases = append(bases, base.ToSQL(func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]*User{}) }))
+			return base.Where("id <= ?", 60)
+		})
+		for _, opts := range [][]Option{
+			{countQuery},
+			{countQuery, WithCountTimeout(10 * time.Second)},
+		} {
+			p := relay.New(
+				f(db.Where("age < ?", 51).Session(&gorm.Session{}), opts...),
+				relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+				relay.EnsureLimits[*User](10, 10),
+			)
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				First: lo.ToPtr(5),
+			})
+			require.NoError(t, err)
+			require.Len(t, conn.Edges, 5)
+			require.Equal(t, 51, conn.Nodes[0].ID)
+			require.Equal(t, lo.ToPtr(10), conn.TotalCount)
+			require.True(t, conn.TotalCountIsExact)
+		}
+		require.Len(t, bases, 2)
+		for _, base := range bases {
+			require.Contains(t, base, "age < 51")
+			require.NotContains(t, base, "id <= 60")
+		}
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestWithDistinctNodes(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("CREATE TABLE user_tags (user_id INT NOT NULL, tag TEXT NOT NULL)").Error)
+	t.Cleanup(func() {
+		require.NoError(t, db.Exec("DROP TABLE user_tags").Error)
+	})
+	require.NoError(t, db.Exec("INSERT INTO user_tags (user_id, tag) SELECT id, 'a' FROM users").Error)
+	require.NoError(t, db.Exec("INSERT INTO user_tags (user_id, tag) SELECT id, 'b' FROM users WHERE id <= 50").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(db.Joins("JOIN user_tags ON user_tags.user_id = users.id").Session(&gorm.Session{}), WithDistinctNodes())),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		var ids []int
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After: after,
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Equal(t, 100, *conn.TotalCount)
+			for _, edge := range conn.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Len(t, ids, 100)
+		require.Len(t, lo.Uniq(ids), 100)
+
+		joined := db.Joins("JOIN user_tags ON user_tags.user_id = users.id").Session(&gorm.Session{})
+		aliased := db.Joins("JOIN user_tags ON user_tags.user_id = users.id").Select("users.id, users.name, users.age * 2 AS age").Session(&gorm.Session{})
+		for _, tc := range []struct {
+			db       *gorm.DB
+			opt      Option
+			orderBys []relay.OrderBy
+			conflict string
+		}{
+			{joined, WithPinnedFirst([]any{3}), nil, "WithPinnedFirst"},
+			{joined, WithSeededRandomOrder("seed"), nil, "WithSeededRandomOrder"},
+			{joined, WithCaseInsensitiveFields(), []relay.OrderBy{{Field: "Name", Fold: true}}, `the folded order by "Name"`},
+			{joined, WithSortKey("Name", "age || '-' || name"), []relay.OrderBy{{Field: "Name"}}, `the sort key of "Name" without WithSelectColumns`},
+			{aliased, WithCaseInsensitiveFields(), []relay.OrderBy{{Field: "age"}}, `the order by "Age" of a select alias`},
+		} {
+			_, err := relay.New(
+				f(tc.db, WithDistinctNodes(), tc.opt),
+				relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10), OrderBys: tc.orderBys})
+			require.ErrorContains(t, err, "WithDistinctNodes cannot be combined with "+tc.conflict)
+		}
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestKeysetCursorForPrimaryKey(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+
+	after, err := KeysetCursorForPrimaryKey[*User](context.Background(), db, 42, orderBys)
+	require.NoError(t, err)
+	require.Equal(t, `{"Age":59,"ID":42}`, after)
+
+	p := relay.New(
+		cursor.Base64(NewKeysetAdapter[*User](db)),
+		relay.EnsureLimits[*User](10, 10),
+	)
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After:    lo.ToPtr(base64.RawURLEncoding.EncodeToString([]byte(after))),
+		First:    lo.ToPtr(3),
+		OrderBys: orderBys,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{43, 44, 45}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+
+	before, err := KeysetCursorForPrimaryKey[any](context.Background(), db.Model(&User{}), 42, []relay.OrderBy{{Field: "Name"}}, WithPrimaryKey("ID"))
+	require.NoError(t, err)
+	require.Equal(t, `{"ID":42,"Name":"name41"}`, before)
+
+	_, err = KeysetCursorForPrimaryKey[*User](context.Background(), db, 1000, orderBys)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestAround(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "ID", Desc: false}}
+	ids := func(conn *relay.Connection[*User]) []int {
+		return lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID })
+	}
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User], anchorOf func(id int) string) {
+		var counts int
+		countingDB := db.Scopes(func(tx *gorm.DB) *gorm.DB {
+			if _, ok := tx.Statement.Dest.(*int64); ok {
+				counts++
+			}
+			return tx
+		}).Session(&gorm.Session{})
+		p := relay.New(
+			cursor.Base64(f(countingDB, WithFacets("Age"))),
+			relay.EnsureLimits[*User](10, 10),
+		)
+
+		conn, err := relay.Around(context.Background(), p, anchorOf(50), 3, 3, orderBys...)
+		require.NoError(t, err)
+		require.Equal(t, []int{47, 48, 49, 51, 52, 53}, ids(conn))
+		require.Len(t, conn.Edges, 6)
+		require.Equal(t, lo.ToPtr(100), conn.TotalCount)
+		require.Len(t, conn.Facets, 100)
+		require.Equal(t, 1, counts)
+		require.Equal(t, 6, conn.EffectiveLimit)
+		require.True(t, conn.PageInfo.HasPreviousPage)
+		require.True(t, conn.PageInfo.HasNextPage)
+		require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+		require.Equal(t, conn.Edges[5].Cursor, *conn.PageInfo.EndCursor)
+
+		conn, err = relay.Around(context.Background(), p, anchorOf(2), 3, 3, orderBys...)
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 3, 4, 5}, ids(conn))
+		require.False(t, conn.PageInfo.HasPreviousPage)
+		require.True(t, conn.PageInfo.HasNextPage)
+
+		conn, err = relay.Around(context.Background(), p, anchorOf(99), 0, 3, orderBys...)
+		require.NoError(t, err)
+		require.Equal(t, []int{100}, ids(conn))
+		require.True(t, conn.PageInfo.HasPreviousPage)
+		require.False(t, conn.PageInfo.HasNextPage)
+		require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+
+		_, err = relay.Around(context.Background(), p, anchorOf(50), -1, 3, orderBys...)
+		require.ErrorContains(t, err, "before and after must be non-negative integers")
+	}
+
+	t.Run("keyset", func(t *testing.T) {
+		testCase(t, NewKeysetAdapter, func(id int) string {
+			anchor, err := cursor.KeysetCursorForNode(&User{ID: id}, orderBys)
+			require.NoError(t, err)
+			return base64.RawURLEncoding.EncodeToString([]byte(anchor))
+		})
+	})
+	t.Run("offset", func(t *testing.T) {
+		testCase(t, NewOffsetAdapter, func(id int) string {
+			return base64.RawURLEncoding.EncodeToString([]byte(cursor.EncodeOffsetCursor(id - 1)))
+		})
+	})
+}
+
+func TestTail(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		cursor.Base64(NewKeysetAdapter[*User](db)),
+		relay.EnsureLimits[*User](10, 10),
+	)
+	ids := func(conn *relay.Connection[*User]) []int {
+		return lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID })
+	}
+	// the orderBys are ascending even if requested descending
+	orderBys := []relay.OrderBy{{Field: "ID", Desc: true}}
+
+	conn, err := relay.Tail(context.Background(), p, "", 3, orderBys...)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, ids(conn))
+
+	after, err := cursor.KeysetCursorForNode(&User{ID: 97}, []relay.OrderBy{{Field: "ID"}})
+	require.NoError(t, err)
+	after = base64.RawURLEncoding.EncodeToString([]byte(after))
+
+	conn, err = relay.Tail(context.Background(), p, after, 2, orderBys...)
+	require.NoError(t, err)
+	require.Equal(t, []int{98, 99}, ids(conn))
+	require.True(t, conn.PageInfo.HasNextPage)
+
+	conn, err = relay.Tail(context.Background(), p, *conn.PageInfo.EndCursor, 2, orderBys...)
+	require.NoError(t, err)
+	require.Equal(t, []int{100}, ids(conn))
+	require.False(t, conn.PageInfo.HasNextPage)
+	last := *conn.PageInfo.EndCursor
+
+	// an empty poll keeps the cursor
+	conn, err = relay.Tail(context.Background(), p, last, 2, orderBys...)
+	require.NoError(t, err)
+	require.Empty(t, conn.Nodes)
+	require.Equal(t, last, *conn.PageInfo.StartCursor)
+	require.Equal(t, last, *conn.PageInfo.EndCursor)
+	require.False(t, conn.PageInfo.HasNextPage)
+
+	require.NoError(t, db.Create(&User{ID: 101, Name: "name100", Age: 0}).Error)
+	conn, err = relay.Tail(context.Background(), p, *conn.PageInfo.EndCursor, 2, orderBys...)
+	require.NoError(t, err)
+	require.Equal(t, []int{101}, ids(conn))
+
+	_, err = relay.Tail(context.Background(), p, last, 0, orderBys...)
+	require.ErrorContains(t, err, "limit must be greater than 0")
+
+	// the primary orderBy appended by the middlewares is ascending too
+	p = relay.New(
+		cursor.Base64(NewKeysetAdapter[*User](db)),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: true}),
+		relay.EnsureLimits[*User](10, 10),
+	)
+	conn, err = relay.Tail(context.Background(), p, after, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int{98, 99}, ids(conn))
+}
+
+func TestFoldOrderBy(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET name = 'Name' || id WHERE id % 2 = 0").Error)
+	require.NoError(t, db.Exec("UPDATE users SET name = 'name' || id WHERE id % 2 = 1").Error)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Name", Desc: true, Fold: true},
+		{Field: "ID", Desc: false},
+	}
+	var expected []string
+	for i := 1; i <= 100; i++ {
+		expected = append(expected, lo.Ternary(i%2 == 0, "Name", "name")+strconv.Itoa(i))
+	}
+	slices.SortFunc(expected, func(a, b string) int { return strings.Compare(strings.ToLower(b), strings.ToLower(a)) })
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(db)),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		var names []string
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After:    after,
+				First:    lo.ToPtr(7),
+				OrderBys: orderBys,
+			})
+			require.NoError(t, err)
+			for _, edge := range conn.Edges {
+				names = append(names, edge.Node.Name)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Equal(t, expected, names)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestWithLenientNodeProcessor(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			f(db),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+
+		var failed []int
+		ctx := relay.WithLenientNodeProcessor(context.Background(), func(ctx context.Context, node *User) (*User, error) {
+			if node.ID%3 == 0 || node.ID == 10 {
+				return nil, errors.New("mock error")
+			}
+			node.Name = node.Name + "_suffix"
+			return node, nil
+		}, func(ctx context.Context, node *User, err error) {
+			require.ErrorContains(t, err, "mock error")
+			failed = append(failed, node.ID)
+		})
+		conn, err := p.Paginate(ctx, &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Equal(t, lo.ToPtr(100), conn.TotalCount)
+		require.Equal(t, []int{3, 6, 9, 10}, failed)
+		require.Equal(t, 4, conn.DroppedNodes)
+		require.Len(t, conn.Edges, 6)
+		require.Equal(t, []int{1, 2, 4, 5, 7, 8}, lo.Map(conn.Nodes, func(node *User, _ int) int { return node.ID }))
+		require.Equal(t, "name0_suffix", conn.Edges[0].Node.Name)
+		require.Equal(t, conn.Edges[0].Cursor, *(conn.PageInfo.StartCursor))
+		require.Equal(t, conn.Edges[len(conn.Edges)-1].Cursor, *(conn.PageInfo.EndCursor))
+		require.True(t, conn.PageInfo.HasNextPage)
+
+		conn, err = p.Paginate(ctx, &relay.PaginateRequest[*User]{
+			After: conn.PageInfo.EndCursor,
+			First: lo.ToPtr(3),
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, conn.DroppedNodes)
+		require.Equal(t, []int{11}, lo.Map(conn.Nodes, func(node *User, _ int) int { return node.ID }))
+
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Zero(t, conn.DroppedNodes)
+		require.Len(t, conn.Edges, 10)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+type SoftUser struct {
+	ID        int `gorm:"primarykey;not null;"`
+	Age       int `gorm:"not null;"`
+	DeletedAt gorm.DeletedAt
+}
+
+func TestWithDeletedBreakdown(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS soft_users").Error)
+	require.NoError(t, db.AutoMigrate(&SoftUser{}))
+	t.Cleanup(func() {
+		require.NoError(t, db.Exec("DROP TABLE soft_users").Error)
+	})
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, db.Create(&SoftUser{ID: i, Age: i % 2}).Error)
+	}
+	require.NoError(t, db.Where("id <= ?", 10).Delete(&SoftUser{}).Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*SoftUser]) {
+		newPagination := func(db *gorm.DB) relay.Pagination[*SoftUser] {
+			return relay.New(
+				cursor.Base64(f(db, WithDeletedBreakdown())),
+				relay.EnsurePrimaryOrderBy[*SoftUser](relay.OrderBy{Field: "ID", Desc: false}),
+				relay.EnsureLimits[*SoftUser](10, 10),
+			)
+		}
+
+		conn, err := newPagination(db).Paginate(context.Background(), &relay.PaginateRequest[*SoftUser]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 90, *conn.TotalCount)
+		require.Equal(t, lo.ToPtr(10), conn.DeletedCount)
+		require.Equal(t, 11, conn.Nodes[0].ID)
+
+		conn, err = relay.Around(context.Background(), newPagination(db), *conn.PageInfo.EndCursor, 2, 2, relay.OrderBy{Field: "ID"})
+		require.NoError(t, err)
+		require.Equal(t, []int{18, 19, 21, 22}, lo.Map(conn.Nodes, func(u *SoftUser, _ int) int { return u.ID }))
+		require.Equal(t, 90, *conn.TotalCount)
+		require.Equal(t, lo.ToPtr(10), conn.DeletedCount)
+
+		conn, err = newPagination(db.Where("age = ?", 1).Session(&gorm.Session{})).Paginate(context.Background(), &relay.PaginateRequest[*SoftUser]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 45, *conn.TotalCount)
+		require.Equal(t, lo.ToPtr(5), conn.DeletedCount)
+
+		conn, err = newPagination(db.Unscoped()).Paginate(context.Background(), &relay.PaginateRequest[*SoftUser]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 100, *conn.TotalCount)
+		require.Nil(t, conn.DeletedCount)
+
+		conn, err = newPagination(db).Paginate(relay.WithSkip(context.Background(), relay.Skip{TotalCount: true}), &relay.PaginateRequest[*SoftUser]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Nil(t, conn.DeletedCount)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter[*SoftUser]) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter[*SoftUser]) })
+
+	resetDB(t)
+	_, err := relay.New(
+		NewKeysetAdapter[*User](db, WithDeletedBreakdown()),
+		relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.ErrorContains(t, err, "missing gorm.DeletedAt field in User for deleted breakdown")
+}
+
+func TestApproximateCount(t *testing.T) {
+	rows, err := parsePlanRows([]byte(`[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "users", "Plan Rows": 1234}}]`))
+	require.NoError(t, err)
+	require.Equal(t, 1234, rows)
+
+	resetDB(t)
+	require.NoError(t, db.Exec("ANALYZE users").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		newPagination := func(db *gorm.DB) relay.Pagination[*User] {
+			return relay.New(
+				cursor.Base64(f(db, WithApproximateCount())),
+				relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+				relay.EnsureLimits[*User](10, 10),
+			)
+		}
+
+		conn, err := newPagination(db).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 100, *conn.TotalCount) // analyzed, so the estimate is accurate
+		require.False(t, conn.TotalCountIsExact)
+		require.Len(t, conn.Nodes, 10)
+
+		// filtered queries are counted exactly
+		conn, err = newPagination(db.Where("age > ?", 50).Session(&gorm.Session{})).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 50, *conn.TotalCount)
+		require.True(t, conn.TotalCountIsExact)
+
+		// an estimate that is too low is only reported, the rows are still fetched by the cursors
+		require.NoError(t, db.Exec("UPDATE pg_class SET reltuples = 5 WHERE oid = 'users'::regclass").Error)
+		defer func() { require.NoError(t, db.Exec("ANALYZE users").Error) }()
+
+		conn, err = newPagination(db).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 5, *conn.TotalCount)
+		require.False(t, conn.TotalCountIsExact)
+		require.Len(t, conn.Nodes, 10)
+
+		conn, err = newPagination(db).Paginate(context.Background(), &relay.PaginateRequest[*User]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 11, conn.Nodes[0].ID)
+		require.True(t, conn.PageInfo.HasPreviousPage)
+		require.True(t, conn.PageInfo.HasNextPage)
+
+		// the last page is located by the exact count
+		conn, err = newPagination(db).Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(3)})
+		require.NoError(t, err)
+		require.Equal(t, []int{98, 99, 100}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+		require.Equal(t, 100, *conn.TotalCount)
+		require.True(t, conn.TotalCountIsExact)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestWithConcurrentCount(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		// the count waits for the find to start, which only happens in time if they run concurrently
+		var overlapped bool
+		findStarted := make(chan struct{})
+		var once sync.Once
+		overlapDB := db.Where("age > ?", 50).Scopes(func(tx *gorm.DB) *gorm.DB {
+			if _, ok := tx.Statement.Dest.(*int64); ok {
+				select {
+				case <-findStarted:
+					overlapped = true
+				case <-time.After(time.Second):
+				}
+			} else {
+				once.Do(func() { close(findStarted) })
+			}
+			return tx
+		}).Session(&gorm.Session{})
+
+		p := relay.New(
+			cursor.Base64(f(overlapDB, WithConcurrentCount())),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.True(t, overlapped)
+		require.Equal(t, 50, *conn.TotalCount)
+		require.True(t, conn.TotalCountIsExact)
+		require.Equal(t, 1, conn.Nodes[0].ID)
+		require.Len(t, conn.Nodes, 10)
+
+		// the offset of the last page depends on the count
+		p = relay.New(
+			cursor.Base64(f(db.Where("age > ?", 50).Session(&gorm.Session{}), WithConcurrentCount())),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Equal(t, 50, *conn.TotalCount)
+		require.Equal(t, 41, conn.Nodes[0].ID)
+
+		conn, err = p.Paginate(relay.WithSkip(context.Background(), relay.Skip{TotalCount: true}), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Nil(t, conn.TotalCount)
+		require.Len(t, conn.Nodes, 10)
+
+		_, err = relay.New(
+			cursor.Base64(f(db.Where("unknown_column = ?", 1).Session(&gorm.Session{}), WithConcurrentCount())),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.Error(t, err)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+type UserWithCompany struct {
+	ID          int
+	Name        string
+	Age         int
+	CompanyName string
+}
+
+func TestSelectAliases(t *testing.T) {
+	require.Equal(t, map[string]string{
+		"company_name": "c.name",
+		"total":        "COALESCE(a, b)",
+	}, selectAliases([]string{`u.*, c.name AS company_name`, `COALESCE(a, b) as "Total"`, `lower(x)`}))
+
+	resetDB(t)
+	require.NoError(t, db.Exec("CREATE TABLE companies (id INT NOT NULL, name TEXT NOT NULL)").Error)
+	t.Cleanup(func() {
+		require.NoError(t, db.Exec("DROP TABLE companies").Error)
+	})
+	require.NoError(t, db.Exec("INSERT INTO companies (id, name) VALUES (0, 'zeta'), (1, 'alpha'), (2, 'mu')").Error)
+
+	base := db.Table("users AS u").
+		Joins("JOIN companies c ON c.id = u.id % 3").
+		Select("u.*, c.name AS company_name").
+		Session(&gorm.Session{})
+	orderBys := []relay.OrderBy{
+		{Field: "CompanyName", Desc: false},
+		{Field: "ID", Desc: true},
+	}
+
+	var expected []*UserWithCompany
+	require.NoError(t, base.Order("company_name").Order("u.id DESC").Find(&expected).Error)
+	require.Len(t, expected, 100)
+	require.Equal(t, "alpha", expected[0].CompanyName)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*UserWithCompany]) {
+		p := relay.New(
+			cursor.Base64(f(base)),
+			relay.EnsureLimits[*UserWithCompany](10, 10),
+		)
+		var nodes []*UserWithCompany
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*UserWithCompany]{
+				After:    after,
+				First:    lo.ToPtr(7),
+				OrderBys: orderBys,
+			})
+			require.NoError(t, err)
+			require.Equal(t, 100, *conn.TotalCount)
+			nodes = append(nodes, conn.Nodes...)
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Equal(t, expected, nodes)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestWithSelectColumns(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(db, WithSelectColumns([]string{"Name"}))),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+			relay.EnsureLimits[*User](10, 10),
+		)
+
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+		require.NoError(t, err)
+		require.Equal(t, []*User{
+			{ID: 1, Name: "name0"},
+			{ID: 2, Name: "name1"},
+			{ID: 3, Name: "name2"},
+		}, conn.Nodes)
+
+		// the ordered fields are selected for the cursors
+		orderBys := []relay.OrderBy{{Field: "Age", Desc: true}}
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(2), OrderBys: orderBys})
+		require.NoError(t, err)
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After:    conn.PageInfo.EndCursor,
+			First:    lo.ToPtr(2),
+			OrderBys: orderBys,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []*User{
+			{ID: 3, Name: "name2", Age: 98},
+			{ID: 4, Name: "name3", Age: 97},
+		}, conn.Nodes)
+
+		_, err = relay.New(
+			f(db.Select("id").Session(&gorm.Session{}), WithSelectColumns([]string{"Name"})),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID", Desc: false}),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+		require.ErrorContains(t, err, "WithSelectColumns cannot be combined with Select on db")
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
@@ -0,0 +1,276 @@
+// Package sqlitetest tests gormrelay on sqlite, apart from the postgres tests of gormrelay which need docker
+package sqlitetest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+	"github.com/theplant/relay/gormrelay"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Task struct {
+	ID       int       `gorm:"primarykey;not null;" json:"id"`
+	Title    string    `gorm:"not null;" json:"title"`
+	Done     bool      `gorm:"not null;" json:"done"`
+	Priority int       `gorm:"not null;" json:"priority"`
+	DueAt    time.Time `gorm:"not null;" json:"dueAt"`
+}
+
+func openSQLite(t *testing.T) *gorm.DB {
+	sqliteDB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := sqliteDB.DB()
+	require.NoError(t, err)
+	// every connection to an in-memory database has its own database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, sqliteDB.AutoMigrate(&Task{}))
+	titles := []string{"apple", "Apple", "banana", "Émile", "éclair", "zebra", "Zebra", "émile"}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tasks := []*Task{}
+	for i := 0; i < 30; i++ {
+		tasks = append(tasks, &Task{
+			Title:    titles[i%len(titles)],
+			Done:     i%3 == 0,
+			Priority: i % 4,
+			DueAt:    base.Add(time.Duration(i%5) * 90 * time.Minute).Add(time.Duration(i%2) * 500 * time.Millisecond),
+		})
+	}
+	require.NoError(t, sqliteDB.Create(tasks).Error)
+	return sqliteDB
+}
+
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+func TestSQLiteKeysetMatrix(t *testing.T) {
+	sqliteDB := openSQLite(t)
+
+	var all []*Task
+	require.NoError(t, sqliteDB.Find(&all).Error)
+
+	byID := func(a, b *Task) int { return cmp.Compare(a.ID, b.ID) }
+	boolInt := func(v bool) int { return lo.Ternary(v, 1, 0) }
+	testCases := []struct {
+		name     string
+		orderBys []relay.OrderBy
+		opts     []gormrelay.Option
+		compare  func(a, b *Task) int
+	}{
+		{
+			name:     "int asc",
+			orderBys: []relay.OrderBy{{Field: "Priority"}, {Field: "ID"}},
+			compare: func(a, b *Task) int {
+				return cmp.Or(cmp.Compare(a.Priority, b.Priority), byID(a, b))
+			},
+		},
+		{
+			name:     "int desc and time",
+			orderBys: []relay.OrderBy{{Field: "Priority", Desc: true}, {Field: "DueAt"}, {Field: "ID"}},
+			compare: func(a, b *Task) int {
+				return cmp.Or(cmp.Compare(b.Priority, a.Priority), a.DueAt.Compare(b.DueAt), byID(a, b))
+			},
+		},
+		{
+			name:     "bool",
+			orderBys: []relay.OrderBy{{Field: "Done"}, {Field: "ID", Desc: true}},
+			compare: func(a, b *Task) int {
+				return cmp.Or(cmp.Compare(boolInt(a.Done), boolInt(b.Done)), byID(b, a))
+			},
+		},
+		{
+			name:     "bool desc",
+			orderBys: []relay.OrderBy{{Field: "Done", Desc: true}, {Field: "Title"}, {Field: "ID"}},
+			compare: func(a, b *Task) int {
+				return cmp.Or(cmp.Compare(boolInt(b.Done), boolInt(a.Done)), cmp.Compare(a.Title, b.Title), byID(a, b))
+			},
+		},
+		{
+			name:     "fold",
+			orderBys: []relay.OrderBy{{Field: "Title", Fold: true}, {Field: "ID"}},
+			compare: func(a, b *Task) int {
+				// LOWER of sqlite only folds ASCII letters
+				return cmp.Or(cmp.Compare(asciiLower(a.Title), asciiLower(b.Title)), byID(a, b))
+			},
+		},
+		{
+			name:     "pinned first",
+			orderBys: []relay.OrderBy{{Field: "Priority"}},
+			opts:     []gormrelay.Option{gormrelay.WithPinnedFirst([]any{7, 18})},
+			compare: func(a, b *Task) int {
+				pinned := func(v *Task) int { return lo.Ternary(v.ID == 7 || v.ID == 18, 0, 1) }
+				return cmp.Or(cmp.Compare(pinned(a), pinned(b)), cmp.Compare(a.Priority, b.Priority), byID(a, b))
+			},
+		},
+		{
+			name:     "sort key",
+			orderBys: []relay.OrderBy{{Field: "Priority", Desc: true}, {Field: "ID"}},
+			opts:     []gormrelay.Option{gormrelay.WithSortKey("Priority", "priority % 3"), gormrelay.WithSelectColumns([]string{"Title"})},
+			compare: func(a, b *Task) int {
+				return cmp.Or(cmp.Compare(b.Priority%3, a.Priority%3), byID(a, b))
+			},
+		},
+	}
+
+	adapters := []struct {
+		name       string
+		newAdapter func(db *gorm.DB, opts ...gormrelay.Option) relay.ApplyCursorsFunc[*Task]
+	}{
+		{name: "keyset", newAdapter: gormrelay.NewKeysetAdapter[*Task]},
+		{name: "offset", newAdapter: gormrelay.NewOffsetAdapter[*Task]},
+	}
+
+	for _, tc := range testCases {
+		expected := slices.Clone(all)
+		slices.SortFunc(expected, tc.compare)
+		expectedIDs := lo.Map(expected, func(v *Task, _ int) int { return v.ID })
+
+		for _, adapter := range adapters {
+			t.Run(fmt.Sprintf("%s/%s", tc.name, adapter.name), func(t *testing.T) {
+				p := relay.New(adapter.newAdapter(sqliteDB, tc.opts...))
+
+				var forward []int
+				var after *string
+				for {
+					conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*Task]{
+						First:    lo.ToPtr(7),
+						After:    after,
+						OrderBys: tc.orderBys,
+					})
+					require.NoError(t, err)
+					require.Equal(t, len(all), *conn.TotalCount)
+					for _, edge := range conn.Edges {
+						forward = append(forward, edge.Node.ID)
+					}
+					if !conn.PageInfo.HasNextPage {
+						break
+					}
+					after = conn.PageInfo.EndCursor
+				}
+				require.Equal(t, expectedIDs, forward)
+
+				var backward []int
+				var before *string
+				for {
+					conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*Task]{
+						Last:     lo.ToPtr(7),
+						Before:   before,
+						OrderBys: tc.orderBys,
+					})
+					require.NoError(t, err)
+					backward = append(lo.Map(conn.Edges, func(edge *relay.Edge[*Task], _ int) int { return edge.Node.ID }), backward...)
+					if !conn.PageInfo.HasPreviousPage {
+						break
+					}
+					before = conn.PageInfo.StartCursor
+				}
+				require.Equal(t, expectedIDs, backward)
+			})
+		}
+	}
+}
+
+func TestSQLiteKeysetSQL(t *testing.T) {
+	sqliteDB := openSQLite(t)
+
+	after, err := cursor.EncodeKeysetCursor(&Task{ID: 3, Done: true, Title: "Émile"}, []string{"Done", "Title", "ID"})
+	require.NoError(t, err)
+	sql, vars, err := gormrelay.ExplainKeyset[*Task](sqliteDB, after, []relay.OrderBy{
+		{Field: "Done"},
+		{Field: "Title", Fold: true},
+		{Field: "ID"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "(`tasks`.`done` > ? OR (`tasks`.`done` = ? AND LOWER(`tasks`.`title`) > LOWER(?)) OR (`tasks`.`done` = ? AND LOWER(`tasks`.`title`) = LOWER(?) AND `tasks`.`id` > ?))", sql)
+	require.Equal(t, []any{true, true, "Émile", true, "Émile", int64(3)}, vars)
+}
+
+func TestSQLiteUnsupported(t *testing.T) {
+	sqliteDB := openSQLite(t)
+
+	paginate := func(opts ...gormrelay.Option) (*relay.Connection[*Task], error) {
+		p := relay.New(gormrelay.NewKeysetAdapter[*Task](sqliteDB, opts...))
+		return p.Paginate(context.Background(), &relay.PaginateRequest[*Task]{
+			First:    lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{{Field: "ID"}},
+		})
+	}
+
+	_, err := paginate(gormrelay.WithSeededRandomOrder("seed"))
+	require.ErrorContains(t, err, "seeded random order is not supported on sqlite")
+
+	_, err = paginate(gormrelay.WithStatementTimeout(time.Second))
+	require.ErrorContains(t, err, "statement timeout is not supported on sqlite")
+
+	_, err = gormrelay.ExplainRequest(context.Background(), sqliteDB, &relay.PaginateRequest[*Task]{
+		First:    lo.ToPtr(5),
+		OrderBys: []relay.OrderBy{{Field: "ID"}},
+	})
+	require.ErrorContains(t, err, "explain is not supported on sqlite")
+
+	// the approximate count falls back to the exact count
+	conn, err := paginate(gormrelay.WithApproximateCount())
+	require.NoError(t, err)
+	require.Equal(t, 30, *conn.TotalCount)
+	require.True(t, conn.TotalCountIsExact)
+}
+
+func TestSQLiteCTEAdapter(t *testing.T) {
+	sqliteDB := openSQLite(t)
+
+	// the CTE is selected as a derived table, which sqlite accepts with WITH RECURSIVE
+	cteSQL := `WITH RECURSIVE chain AS (
+		SELECT * FROM tasks WHERE id = ?
+		UNION ALL
+		SELECT t.* FROM tasks t JOIN chain ON t.id = chain.id + 1 WHERE t.id <= ?
+	) SELECT * FROM chain`
+	p := relay.New(
+		cursor.Base64(gormrelay.NewCTEAdapter[*Task](sqliteDB, cteSQL, []any{5, 24}, "id")),
+		relay.EnsureLimits[*Task](10, 10),
+	)
+
+	var ids []int
+	var after *string
+	for {
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*Task]{
+			After:    after,
+			First:    lo.ToPtr(7),
+			OrderBys: []relay.OrderBy{{Field: "Priority", Desc: true}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 20, *conn.TotalCount)
+		for _, edge := range conn.Edges {
+			ids = append(ids, edge.Node.ID)
+		}
+		if !conn.PageInfo.HasNextPage {
+			break
+		}
+		after = conn.PageInfo.EndCursor
+	}
+
+	expected := lo.RangeFrom(5, 20)
+	slices.SortStableFunc(expected, func(a, b int) int {
+		// the priority of the task with ID id is (id-1)%4
+		return cmp.Compare((b-1)%4, (a-1)%4)
+	})
+	require.Equal(t, expected, ids)
+}
@@ -0,0 +1,67 @@
+package gormrelay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/relay"
+	"gorm.io/gorm"
+)
+
+// withStatementTimeout runs the adapter created by newAdapter in a transaction with `SET LOCAL statement_timeout`
+// if WithStatementTimeout is set, otherwise it creates the adapter on db directly
+func withStatementTimeout[T any](db *gorm.DB, o *options, newAdapter func(db *gorm.DB) relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+	if o.statementTimeout <= 0 {
+		return newAdapter(db)
+	}
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		if db.Dialector.Name() != "postgres" {
+			return nil, errors.Errorf("statement timeout is not supported on %s", db.Dialector.Name())
+		}
+
+		var rsp *relay.ApplyCursorsResponse[T]
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", o.statementTimeout.Milliseconds())).Error; err != nil {
+				return errors.Wrap(err, "set statement timeout")
+			}
+			var err error
+			rsp, err = newAdapter(tx)(ctx, req)
+			return err
+		})
+		if err != nil {
+			if isQueryCanceled(err) && ctx.Err() == nil {
+				return nil, errors.WithStack(&queryTimeoutError{cause: err})
+			}
+			return nil, err
+		}
+		return rsp, nil
+	}
+}
+
+// isQueryCanceled reports whether err is the postgres error query_canceled (57014), which statement_timeout raises
+func isQueryCanceled(err error) bool {
+	var sqlStateErr interface{ SQLState() string }
+	return errors.As(err, &sqlStateErr) && sqlStateErr.SQLState() == "57014"
+}
+
+// queryTimeoutError is relay.ErrQueryTimeout, errors.Cause and errors.Unwrap yield the error of the canceled query
+type queryTimeoutError struct {
+	cause error
+}
+
+func (e *queryTimeoutError) Error() string {
+	return relay.ErrQueryTimeout.Error() + ": " + e.cause.Error()
+}
+
+func (e *queryTimeoutError) Is(target error) bool {
+	return target == relay.ErrQueryTimeout
+}
+
+func (e *queryTimeoutError) Cause() error {
+	return e.cause
+}
+
+func (e *queryTimeoutError) Unwrap() error {
+	return e.cause
+}
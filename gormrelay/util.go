@@ -1,9 +1,15 @@
 package gormrelay
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 )
@@ -16,6 +22,19 @@ func parseSchema(db *gorm.DB, v any) (*schema.Schema, error) {
 	return stmt.Schema, nil
 }
 
+// parseModelSchema parses the schema of db.Statement.Model, or T if the model is not set
+func parseModelSchema[T any](db *gorm.DB) (*schema.Schema, error) {
+	model := db.Statement.Model
+	if model == nil {
+		if _, err := shouldBasedOnModel[T](db); err != nil {
+			return nil, err
+		}
+		var t T
+		model = t
+	}
+	return parseSchema(db, model)
+}
+
 // If T is not a struct or struct pointer, we need to use db.Statement.Model to find or count
 func shouldBasedOnModel[T any](db *gorm.DB) (bool, error) {
 	tType := reflect.TypeOf((*T)(nil)).Elem()
@@ -27,3 +46,344 @@ func shouldBasedOnModel[T any](db *gorm.DB) (bool, error) {
 	}
 	return false, nil
 }
+
+// newModel returns a non-nil pointer to the struct of T, T must be a struct or struct pointer
+func newModel[T any]() any {
+	tType := reflect.TypeOf((*T)(nil)).Elem()
+	if tType.Kind() == reflect.Ptr {
+		tType = tType.Elem()
+	}
+	return reflect.New(tType).Interface()
+}
+
+func lookupFieldFold(s *schema.Schema, name string) *schema.Field {
+	if field, ok := s.FieldsByName[name]; ok {
+		return field
+	}
+	for _, field := range s.Fields {
+		if strings.EqualFold(field.Name, name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// resolveOrderBys replaces the orderBy fields with the names of the matched schema fields.
+// It returns an error if several orderBys resolve to the same field, since it is ambiguous which one applies.
+func resolveOrderBys(s *schema.Schema, orderBys []relay.OrderBy) ([]relay.OrderBy, error) {
+	resolved := make([]relay.OrderBy, 0, len(orderBys))
+	seen := make(map[string]string, len(orderBys))
+	for _, orderBy := range orderBys {
+		name := orderBy.Field
+		if field := lookupFieldFold(s, orderBy.Field); field != nil {
+			orderBy.Field = field.Name
+		}
+		if prev, ok := seen[orderBy.Field]; ok {
+			return nil, errors.Errorf("order by fields %q and %q resolve to the same field %q", prev, name, orderBy.Field)
+		}
+		seen[orderBy.Field] = name
+		resolved = append(resolved, orderBy)
+	}
+	return resolved, nil
+}
+
+func missingFieldError(s *schema.Schema, name string) error {
+	names := lo.Map(s.Fields, func(field *schema.Field, _ int) string { return field.Name })
+	if suggestion := suggest(name, names); suggestion != "" {
+		return errors.Errorf("missing field %q in schema, did you mean %q?", name, suggestion)
+	}
+	return errors.Errorf("missing field %q in schema", name)
+}
+
+// suggest returns the candidate closest to name by case-insensitive edit distance,
+// or an empty string if none of them is close enough
+func suggest(name string, candidates []string) string {
+	threshold := max(1, utf8.RuneCountInString(name)/3)
+	suggestion, best := "", threshold+1
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < best {
+			suggestion, best = candidate, d
+		}
+	}
+	return suggestion
+}
+
+// editDistance is the optimal string alignment distance, a transposition of adjacent runes counts as one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// primaryFields returns the fields set by WithPrimaryKey, or the primary fields detected from the schema
+func primaryFields(s *schema.Schema, o *options) ([]*schema.Field, error) {
+	if len(o.primaryKey) == 0 {
+		if s.PrioritizedPrimaryField != nil {
+			return []*schema.Field{s.PrioritizedPrimaryField}, nil
+		}
+		return s.PrimaryFields, nil
+	}
+
+	fields := make([]*schema.Field, 0, len(o.primaryKey))
+	for _, name := range o.primaryKey {
+		var field *schema.Field
+		if o.caseInsensitiveFields {
+			field = lookupFieldFold(s, name)
+		} else {
+			field = s.FieldsByName[name]
+		}
+		if field == nil {
+			return nil, missingFieldError(s, name)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// primaryField returns the first of primaryFields, which the orders derived from the primary key are based on
+func primaryField(s *schema.Schema, o *options) (*schema.Field, error) {
+	fields, err := primaryFields(s, o)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("missing primary key, use WithPrimaryKey to specify it")
+	}
+	return fields[0], nil
+}
+
+// prepareOrderBys resolves the orderBys against the schema and appends the primary key if the options require it
+func prepareOrderBys[T any](db *gorm.DB, o *options, orderBys []relay.OrderBy) ([]relay.OrderBy, error) {
+	if o.caseInsensitiveFields && len(orderBys) > 0 {
+		s, err := parseModelSchema[T](db)
+		if err != nil {
+			return nil, err
+		}
+		orderBys, err = resolveOrderBys(s, orderBys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.randomSeed != nil || len(o.pinnedIDs) > 0 || len(o.primaryKey) > 0 {
+		s, err := parseModelSchema[T](db)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := primaryFields(s, o)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, errors.New("missing primary key, use WithPrimaryKey to specify it")
+		}
+		orderBys = relay.AppendPrimaryOrderBy(orderBys, lo.Map(fields, func(field *schema.Field, _ int) relay.OrderBy {
+			return relay.OrderBy{Field: field.Name}
+		})...)
+	}
+
+	if o.autoTiebreaker {
+		s, err := parseModelSchema[T](db)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := primaryFields(s, o)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, errors.New("missing primary key, use WithPrimaryKey to specify it")
+		}
+		if !uniqueOrder(s, o, fields, orderBys) {
+			primaryOrderBys := lo.Map(fields, func(field *schema.Field, _ int) relay.OrderBy {
+				return relay.OrderBy{Field: field.Name}
+			})
+			db.Logger.Info(db.Statement.Context, "gormrelay: order by %v is not unique, appending the primary key %v as a tiebreaker", orderBys, primaryOrderBys)
+			orderBys = relay.AppendPrimaryOrderBy(orderBys, primaryOrderBys...)
+		}
+	}
+	if err := checkDistinctOrder[T](db, o, orderBys); err != nil {
+		return nil, err
+	}
+	return orderBys, nil
+}
+
+// uniqueOrder reports whether the orderBys identify a row, i.e. they cover the primary fields or a unique index of not null columns.
+// Folded orderBys and sort keys compare expressions of the columns, so they do not count.
+func uniqueOrder(s *schema.Schema, o *options, primaryFields []*schema.Field, orderBys []relay.OrderBy) bool {
+	plain := make(map[string]bool, len(orderBys))
+	for _, orderBy := range orderBys {
+		if _, ok := o.sortKeys[orderBy.Field]; ok || orderBy.Fold {
+			continue
+		}
+		plain[orderBy.Field] = true
+	}
+	covered := func(fields []*schema.Field) bool {
+		return len(fields) > 0 && lo.EveryBy(fields, func(field *schema.Field) bool {
+			return field != nil && plain[field.Name] && (field.NotNull || field.PrimaryKey)
+		})
+	}
+
+	if covered(primaryFields) {
+		return true
+	}
+	for _, field := range s.Fields {
+		if field.Unique && covered([]*schema.Field{field}) {
+			return true
+		}
+	}
+	for _, index := range s.ParseIndexes() {
+		if index.Class != "UNIQUE" || index.Where != "" {
+			continue
+		}
+		if covered(lo.Map(index.Fields, func(option schema.IndexOption, _ int) *schema.Field { return option.Field })) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDistinctOrder rejects ordering by expressions with WithDistinctNodes, since SELECT DISTINCT requires
+// the ORDER BY expressions to appear in the select list, which postgres enforces and other databases may not.
+// Sort keys are only allowed with WithSelectColumns, which selects them.
+func checkDistinctOrder[T any](db *gorm.DB, o *options, orderBys []relay.OrderBy) error {
+	if !o.distinctNodes {
+		return nil
+	}
+	var conflict string
+	switch {
+	case len(o.pinnedIDs) > 0:
+		conflict = "WithPinnedFirst"
+	case o.randomSeed != nil:
+		conflict = "WithSeededRandomOrder"
+	default:
+		aliases := selectAliases(db.Statement.Selects)
+		var s *schema.Schema
+		if len(aliases) > 0 {
+			var err error
+			s, err = parseModelSchema[T](db)
+			if err != nil {
+				return err
+			}
+		}
+		for _, orderBy := range orderBys {
+			if orderBy.Fold {
+				conflict = fmt.Sprintf("the folded order by %q", orderBy.Field)
+				break
+			}
+			if _, ok := o.sortKeys[orderBy.Field]; ok {
+				if len(o.selectFields) == 0 {
+					conflict = fmt.Sprintf("the sort key of %q without WithSelectColumns", orderBy.Field)
+					break
+				}
+				continue
+			}
+			if s == nil {
+				continue
+			}
+			if field, ok := s.FieldsByName[orderBy.Field]; ok {
+				if _, ok := aliases[strings.ToLower(field.DBName)]; ok {
+					conflict = fmt.Sprintf("the order by %q of a select alias", orderBy.Field)
+					break
+				}
+			}
+		}
+	}
+	if conflict == "" {
+		return nil
+	}
+	return errors.Errorf("WithDistinctNodes cannot be combined with %s, the ORDER BY expression would not be in the SELECT DISTINCT list", conflict)
+}
+
+// wrapAdapter applies the adapter level options to the request before it reaches next
+func wrapAdapter[T any](db *gorm.DB, o *options, next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		orderBys, err := prepareOrderBys[T](db, o, req.OrderBys)
+		if err != nil {
+			return nil, err
+		}
+		req.OrderBys = orderBys
+
+		skip := relay.GetSkip(ctx)
+		var deletedCount *int
+		if o.deletedBreakdown && !skip.TotalCount {
+			deletedCount, err = countDeleted[T](ctx, db, o)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var estimatedCount *int
+		var waitCount func() (int, error)
+		if !skip.TotalCount {
+			ctx, estimatedCount, err = prepareTotalCount[T](ctx, db, o, req)
+			if err != nil {
+				return nil, err
+			}
+			if o.concurrentCount {
+				var cancelCount func()
+				ctx, waitCount, cancelCount = countConcurrently[T](ctx, db, o, req)
+				defer cancelCount()
+			}
+		}
+
+		rsp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if waitCount != nil {
+			count, err := waitCount()
+			if err != nil {
+				return nil, err
+			}
+			rsp.TotalCount = &count
+		}
+
+		rsp.DeletedCount = deletedCount
+		if estimatedCount != nil {
+			rsp.TotalCount = estimatedCount
+			rsp.TotalCountIsEstimated = true
+		}
+
+		if o.facetField != "" && !skip.Facets {
+			facets, err := countFacets[T](ctx, db, o.facetField)
+			if err != nil {
+				return nil, err
+			}
+			rsp.Facets = facets
+		}
+
+		if len(o.distinctValues) > 0 && !skip.DistinctValues {
+			rsp.DistinctValues = make(map[string][]any, len(o.distinctValues))
+			for field, limit := range o.distinctValues {
+				values, err := listDistinctValues[T](ctx, db, field, limit)
+				if err != nil {
+					return nil, err
+				}
+				rsp.DistinctValues[field] = values
+			}
+		}
+
+		return rsp, nil
+	}
+}
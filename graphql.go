@@ -0,0 +1,28 @@
+package relay
+
+import "github.com/pkg/errors"
+
+// ParseGraphQLConnectionArgs builds a request from the arguments of a GraphQL connection field, e.g. with gqlgen.
+// It only validates the arguments themselves, the limits are still applied by the middlewares like EnsureLimits,
+// so first and last may both be nil here if a default limit is configured.
+func ParseGraphQLConnectionArgs[T any](first *int, after *string, last *int, before *string, orderBys []OrderBy) (*PaginateRequest[T], error) {
+	if first != nil && last != nil {
+		return nil, errors.New("first and last cannot be used together")
+	}
+	if first != nil && *first < 0 {
+		return nil, errors.New("first must be a non-negative integer")
+	}
+	if last != nil && *last < 0 {
+		return nil, errors.New("last must be a non-negative integer")
+	}
+	if err := checkOrderBys(orderBys); err != nil {
+		return nil, err
+	}
+	return &PaginateRequest[T]{
+		After:    after,
+		First:    first,
+		Before:   before,
+		Last:     last,
+		OrderBys: orderBys,
+	}, nil
+}
@@ -0,0 +1,37 @@
+package relay
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGraphQLConnectionArgs(t *testing.T) {
+	// first and last may both be nil, the default limit is applied by the middlewares
+	req, err := ParseGraphQLConnectionArgs[any](nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, &PaginateRequest[any]{}, req)
+
+	req, err = ParseGraphQLConnectionArgs[any](lo.ToPtr(100), lo.ToPtr("after"), nil, lo.ToPtr("before"), []OrderBy{{Field: "ID"}})
+	require.NoError(t, err)
+	require.Equal(t, &PaginateRequest[any]{
+		After:    lo.ToPtr("after"),
+		Before:   lo.ToPtr("before"),
+		First:    lo.ToPtr(100),
+		OrderBys: []OrderBy{{Field: "ID"}},
+	}, req)
+
+	req, err = ParseGraphQLConnectionArgs[any](nil, nil, lo.ToPtr(0), nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, &PaginateRequest[any]{Last: lo.ToPtr(0)}, req)
+
+	_, err = ParseGraphQLConnectionArgs[any](lo.ToPtr(1), nil, lo.ToPtr(1), nil, nil)
+	require.ErrorContains(t, err, "first and last cannot be used together")
+	_, err = ParseGraphQLConnectionArgs[any](lo.ToPtr(-1), nil, nil, nil, nil)
+	require.ErrorContains(t, err, "first must be a non-negative integer")
+	_, err = ParseGraphQLConnectionArgs[any](nil, nil, lo.ToPtr(-1), nil, nil)
+	require.ErrorContains(t, err, "last must be a non-negative integer")
+	_, err = ParseGraphQLConnectionArgs[any](lo.ToPtr(1), nil, nil, nil, []OrderBy{{Field: "ID"}, {Field: "ID", Desc: true}})
+	require.ErrorContains(t, err, "duplicated order by fields [ID]")
+}
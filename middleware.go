@@ -3,6 +3,7 @@ package relay
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"github.com/samber/lo"
 )
 
@@ -19,8 +20,26 @@ func EnsureLimits[T any](defaultLimit, maxLimit int) PaginationMiddleware[T] {
 	if maxLimit < defaultLimit {
 		panic("maxLimit must be greater than or equal to defaultLimit")
 	}
+	return EnsureLimitsFunc(func(req *PaginateRequest[T]) (int, int) {
+		return defaultLimit, maxLimit
+	})
+}
+
+// EnsureLimitsFunc is like EnsureLimits, but the limits are resolved per request,
+// e.g. to allow a larger first page than the subsequent ones by checking whether After/Before is set.
+func EnsureLimitsFunc[T any](limits func(req *PaginateRequest[T]) (defaultLimit, maxLimit int)) PaginationMiddleware[T] {
+	if limits == nil {
+		panic("limits must be set")
+	}
 	return func(next Pagination[T]) Pagination[T] {
 		return PaginationFunc[T](func(ctx context.Context, req *PaginateRequest[T]) (*Connection[T], error) {
+			defaultLimit, maxLimit := limits(req)
+			if defaultLimit < 0 {
+				return nil, errors.New("defaultLimit cannot be negative")
+			}
+			if maxLimit < defaultLimit {
+				return nil, errors.New("maxLimit must be greater than or equal to defaultLimit")
+			}
 			if req.First != nil {
 				if *req.First > maxLimit {
 					req.First = &maxLimit
@@ -58,6 +77,41 @@ func EnsurePrimaryOrderBy[T any](primaryOrderBys ...OrderBy) PaginationMiddlewar
 	}
 }
 
+// EnsurePrimaryOrderByFunc is like EnsurePrimaryOrderBy, but the primary orderBys are resolved per request,
+// e.g. from the schema of the model, see gormrelay.EnsurePrimaryOrderByFromSchema.
+func EnsurePrimaryOrderByFunc[T any](primaryOrderBys func(ctx context.Context) ([]OrderBy, error)) PaginationMiddleware[T] {
+	if primaryOrderBys == nil {
+		panic("primaryOrderBys must be set")
+	}
+	return func(next Pagination[T]) Pagination[T] {
+		return PaginationFunc[T](func(ctx context.Context, req *PaginateRequest[T]) (*Connection[T], error) {
+			orderBys, err := primaryOrderBys(ctx)
+			if err != nil {
+				return nil, err
+			}
+			req.OrderBys = AppendPrimaryOrderBy(req.OrderBys, orderBys...)
+			return next.Paginate(ctx, req)
+		})
+	}
+}
+
+// EnsureMaxOrderByFields rejects requests with more than maxFields orderBys, since each of them adds a term
+// to the keyset condition and a column to the index it needs. The orderBys are counted right before the adapter runs,
+// so those merged by EnsurePrimaryOrderBy are included wherever it is in the middlewares.
+func EnsureMaxOrderByFields[T any](maxFields int) PaginationMiddleware[T] {
+	if maxFields <= 0 {
+		panic("maxFields must be greater than 0")
+	}
+	return AppendCursorMiddleware(func(next ApplyCursorsFunc[T]) ApplyCursorsFunc[T] {
+		return func(ctx context.Context, req *ApplyCursorsRequest) (*ApplyCursorsResponse[T], error) {
+			if len(req.OrderBys) > maxFields {
+				return nil, errors.Errorf("too many orderBy fields: %d exceeds max %d", len(req.OrderBys), maxFields)
+			}
+			return next(ctx, req)
+		}
+	})
+}
+
 func AppendPrimaryOrderBy(orderBys []OrderBy, primaryOrderBys ...OrderBy) []OrderBy {
 	if len(primaryOrderBys) == 0 {
 		return orderBys
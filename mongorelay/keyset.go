@@ -0,0 +1,80 @@
+package mongorelay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type KeysetFinder[T any] struct {
+	coll   *mongo.Collection
+	filter bson.M
+}
+
+func NewKeysetFinder[T any](coll *mongo.Collection, filter bson.M) *KeysetFinder[T] {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	return &KeysetFinder[T]{coll: coll, filter: filter}
+}
+
+func (a *KeysetFinder[T]) Find(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromEnd bool) ([]T, error) {
+	if limit == 0 {
+		return []T{}, nil
+	}
+
+	ands := bson.A{a.filter}
+	if after != nil {
+		boundary, err := createBoundary[T](orderBys, *after, false)
+		if err != nil {
+			return nil, err
+		}
+		ands = append(ands, boundary)
+	}
+	if before != nil {
+		boundary, err := createBoundary[T](orderBys, *before, true)
+		if err != nil {
+			return nil, err
+		}
+		ands = append(ands, boundary)
+	}
+
+	sort, err := sortOf[T](orderBys, fromEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := a.coll.Find(ctx, bson.M{"$and": ands}, options.Find().SetSort(sort).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, errors.Wrap(err, "find")
+	}
+
+	nodes := []T{}
+	if err := cur.All(ctx, &nodes); err != nil {
+		return nil, errors.Wrap(err, "decode nodes")
+	}
+	if fromEnd {
+		lo.Reverse(nodes)
+	}
+	return nodes, nil
+}
+
+func (a *KeysetFinder[T]) Count(ctx context.Context) (int, error) {
+	count, err := a.coll.CountDocuments(ctx, a.filter)
+	if err != nil {
+		return 0, errors.Wrap(err, "count")
+	}
+	return int(count), nil
+}
+
+// NewKeysetAdapter paginates the documents of coll matching filter with keyset cursors,
+// the orderBys refer to the struct field names of T and are sorted by their bson keys.
+func NewKeysetAdapter[T any](coll *mongo.Collection, filter bson.M) relay.ApplyCursorsFunc[T] {
+	return cursor.NewKeysetAdapter[T](NewKeysetFinder[T](coll, filter))
+}
@@ -0,0 +1,101 @@
+package mongorelay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type User struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	Name      string
+	Age       int       `bson:"age_years"`
+	CreatedAt time.Time `bson:"created_at"`
+	Ignored   string    `bson:"-"`
+}
+
+func TestDocumentKey(t *testing.T) {
+	for name, expected := range map[string]string{
+		"ID":        "_id",
+		"Name":      "name",
+		"Age":       "age_years",
+		"CreatedAt": "created_at",
+	} {
+		key, err := documentKey[*User](name)
+		require.NoError(t, err)
+		require.Equal(t, expected, key)
+	}
+
+	_, err := documentKey[*User]("Ignored")
+	require.ErrorContains(t, err, `field "Ignored" is ignored by bson`)
+	_, err = documentKey[*User]("Unknown")
+	require.ErrorContains(t, err, `missing field "Unknown"`)
+	_, err = documentKey[int]("ID")
+	require.ErrorContains(t, err, "T must be a struct or struct pointer")
+}
+
+func TestSortOf(t *testing.T) {
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+
+	sort, err := sortOf[*User](orderBys, false)
+	require.NoError(t, err)
+	require.Equal(t, bson.D{{Key: "age_years", Value: -1}, {Key: "_id", Value: 1}}, sort)
+
+	sort, err = sortOf[*User](orderBys, true)
+	require.NoError(t, err)
+	require.Equal(t, bson.D{{Key: "age_years", Value: 1}, {Key: "_id", Value: -1}}, sort)
+
+	_, err = sortOf[*User]([]relay.OrderBy{{Field: "Name", Fold: true}}, false)
+	require.ErrorContains(t, err, `fold of "Name" is not supported by mongorelay`)
+}
+
+func TestCreateBoundary(t *testing.T) {
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "Name", Desc: false},
+		{Field: "CreatedAt", Desc: false},
+	}
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	keyset := map[string]any{"Age": int64(20), "Name": "molon", "CreatedAt": createdAt}
+
+	boundary, err := createBoundary[*User](orderBys, keyset, false)
+	require.NoError(t, err)
+	require.Equal(t, bson.M{"$or": bson.A{
+		bson.D{{Key: "age_years", Value: bson.M{"$lt": int64(20)}}},
+		bson.D{{Key: "age_years", Value: int64(20)}, {Key: "name", Value: bson.M{"$gt": "molon"}}},
+		bson.D{{Key: "age_years", Value: int64(20)}, {Key: "name", Value: "molon"}, {Key: "created_at", Value: bson.M{"$gt": createdAt}}},
+	}}, boundary)
+
+	boundary, err = createBoundary[*User](orderBys[:1], keyset, true)
+	require.NoError(t, err)
+	require.Equal(t, bson.M{"$or": bson.A{
+		bson.D{{Key: "age_years", Value: bson.M{"$gt": int64(20)}}},
+	}}, boundary)
+
+	_, err = createBoundary[*User](orderBys, map[string]any{"Age": 1}, false)
+	require.ErrorContains(t, err, `missing field "Name" in keyset`)
+}
+
+func TestCreateBoundaryObjectID(t *testing.T) {
+	id := bson.NewObjectID()
+	after, err := cursor.KeysetCursorForNode(&User{ID: id}, []relay.OrderBy{{Field: "ID"}})
+	require.NoError(t, err)
+	keyset, err := cursor.DecodeKeysetCursor[*User](after, []string{"ID"})
+	require.NoError(t, err)
+
+	boundary, err := createBoundary[*User]([]relay.OrderBy{{Field: "ID"}}, keyset, false)
+	require.NoError(t, err)
+	require.Equal(t, bson.M{"$or": bson.A{
+		bson.D{{Key: "_id", Value: bson.M{"$gt": id}}},
+	}}, boundary)
+
+	_, err = createBoundary[*User]([]relay.OrderBy{{Field: "ID"}}, map[string]any{"ID": "invalid"}, false)
+	require.ErrorContains(t, err, `decode key "ID"`)
+}
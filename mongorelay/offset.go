@@ -0,0 +1,67 @@
+package mongorelay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type OffsetFinder[T any] struct {
+	coll   *mongo.Collection
+	filter bson.M
+}
+
+func NewOffsetFinder[T any](coll *mongo.Collection, filter bson.M) *OffsetFinder[T] {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	return &OffsetFinder[T]{coll: coll, filter: filter}
+}
+
+func (a *OffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]T, error) {
+	if limit == 0 {
+		return []T{}, nil
+	}
+
+	opts := options.Find().SetLimit(int64(limit))
+	if skip > 0 {
+		opts = opts.SetSkip(int64(skip))
+	}
+	if len(orderBys) > 0 {
+		sort, err := sortOf[T](orderBys, false)
+		if err != nil {
+			return nil, err
+		}
+		opts = opts.SetSort(sort)
+	}
+
+	cur, err := a.coll.Find(ctx, a.filter, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "find")
+	}
+
+	nodes := []T{}
+	if err := cur.All(ctx, &nodes); err != nil {
+		return nil, errors.Wrap(err, "decode nodes")
+	}
+	return nodes, nil
+}
+
+func (a *OffsetFinder[T]) Count(ctx context.Context) (int, error) {
+	count, err := a.coll.CountDocuments(ctx, a.filter)
+	if err != nil {
+		return 0, errors.Wrap(err, "count")
+	}
+	return int(count), nil
+}
+
+// NewOffsetAdapter paginates the documents of coll matching filter with offset cursors,
+// the orderBys refer to the struct field names of T and are sorted by their bson keys.
+func NewOffsetAdapter[T any](coll *mongo.Collection, filter bson.M) relay.ApplyCursorsFunc[T] {
+	return cursor.NewOffsetAdapter[T](NewOffsetFinder[T](coll, filter))
+}
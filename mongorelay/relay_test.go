@@ -0,0 +1,204 @@
+package mongorelay
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// mongoDB is nil if neither MONGODB_URI is set nor a mongo container can be started, the tests needing it are skipped then
+var mongoDB *mongo.Database
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+			ContainerRequest: testcontainers.ContainerRequest{
+				Image:        "mongo:7",
+				ExposedPorts: []string{"27017/tcp"},
+				WaitingFor:   wait.ForListeningPort("27017/tcp"),
+			},
+			Started: true,
+		})
+		if err != nil {
+			log.Printf("skip the tests needing mongo: %v", err)
+			os.Exit(m.Run())
+		}
+		uri, err = container.Endpoint(ctx, "mongodb")
+		if err != nil {
+			panic(err)
+		}
+		defer container.Terminate(ctx)
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		panic(err)
+	}
+	defer client.Disconnect(ctx)
+	mongoDB = client.Database("relay_test")
+
+	code := m.Run()
+	if err := mongoDB.Drop(ctx); err != nil {
+		log.Printf("drop database: %v", err)
+	}
+	os.Exit(code)
+}
+
+// resetColl fills a collection with 100 users, the ages repeat every 10 users so that ID breaks the ties
+func resetColl(t *testing.T) (*mongo.Collection, []*User) {
+	if mongoDB == nil {
+		t.Skip("mongo is not available")
+	}
+
+	coll := mongoDB.Collection(t.Name())
+	require.NoError(t, coll.Drop(context.Background()))
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	users := make([]*User, 100)
+	for i := range users {
+		users[i] = &User{
+			ID:        bson.NewObjectIDFromTimestamp(base.Add(time.Duration(i) * time.Second)),
+			Name:      fmt.Sprintf("name%02d", i),
+			Age:       i % 10,
+			CreatedAt: base.Add(time.Duration(i%7) * time.Hour),
+		}
+	}
+	_, err := coll.InsertMany(context.Background(), users)
+	require.NoError(t, err)
+	return coll, users
+}
+
+func TestPaginate(t *testing.T) {
+	coll, users := resetColl(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "CreatedAt"},
+		{Field: "ID"},
+	}
+	filter := bson.M{"age_years": bson.M{"$gte": 2}}
+	expected := lo.Filter(users, func(u *User, _ int) bool { return u.Age >= 2 })
+	slices.SortFunc(expected, func(a, b *User) int {
+		return cmp.Or(cmp.Compare(b.Age, a.Age), a.CreatedAt.Compare(b.CreatedAt), bytes.Compare(a.ID[:], b.ID[:]))
+	})
+	expectedIDs := lo.Map(expected, func(u *User, _ int) bson.ObjectID { return u.ID })
+
+	testCase := func(t *testing.T, newAdapter func(coll *mongo.Collection, filter bson.M) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(newAdapter(coll, filter)),
+			relay.EnsurePrimaryOrderBy[*User](relay.OrderBy{Field: "ID"}),
+			relay.EnsureLimits[*User](7, 7),
+		)
+
+		var forward []bson.ObjectID
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After:    after,
+				First:    lo.ToPtr(7),
+				OrderBys: orderBys,
+			})
+			require.NoError(t, err)
+			require.Equal(t, len(expected), *conn.TotalCount)
+			require.Equal(t, after != nil, conn.PageInfo.HasPreviousPage)
+			for _, edge := range conn.Edges {
+				forward = append(forward, edge.Node.ID)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Equal(t, expectedIDs, forward)
+
+		var backward []bson.ObjectID
+		var before *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				Before:   before,
+				Last:     lo.ToPtr(7),
+				OrderBys: orderBys,
+			})
+			require.NoError(t, err)
+			require.Equal(t, before != nil, conn.PageInfo.HasNextPage)
+			backward = append(lo.Map(conn.Edges, func(edge *relay.Edge[*User], _ int) bson.ObjectID { return edge.Node.ID }), backward...)
+			if !conn.PageInfo.HasPreviousPage {
+				break
+			}
+			before = conn.PageInfo.StartCursor
+		}
+		require.Equal(t, expectedIDs, backward)
+
+		// a window between after and before
+		conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(7),
+			OrderBys: orderBys,
+		})
+		require.NoError(t, err)
+		windowAfter := conn.Edges[1].Cursor
+		windowBefore := conn.Edges[5].Cursor
+		conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After:    &windowAfter,
+			Before:   &windowBefore,
+			First:    lo.ToPtr(7),
+			OrderBys: orderBys,
+		})
+		require.NoError(t, err)
+		require.Equal(t, expectedIDs[2:5], lo.Map(conn.Nodes, func(u *User, _ int) bson.ObjectID { return u.ID }))
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter[*User]) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter[*User]) })
+}
+
+func TestFinders(t *testing.T) {
+	coll, users := resetColl(t)
+	ctx := context.Background()
+	orderBys := []relay.OrderBy{{Field: "Name", Desc: true}}
+
+	keysetFinder := NewKeysetFinder[*User](coll, nil)
+	count, err := keysetFinder.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+
+	nodes, err := keysetFinder.Find(ctx, nil, nil, orderBys, 3, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"name99", "name98", "name97"}, lo.Map(nodes, func(u *User, _ int) string { return u.Name }))
+	require.Equal(t, users[99].CreatedAt, nodes[0].CreatedAt)
+
+	nodes, err = keysetFinder.Find(ctx, &map[string]any{"Name": "name10"}, nil, orderBys, 3, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"name02", "name01", "name00"}, lo.Map(nodes, func(u *User, _ int) string { return u.Name }))
+
+	offsetFinder := NewOffsetFinder[*User](coll, bson.M{"age_years": 3})
+	count, err = offsetFinder.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+
+	nodes, err = offsetFinder.Find(ctx, orderBys, 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"name73", "name63", "name53"}, lo.Map(nodes, func(u *User, _ int) string { return u.Name }))
+
+	nodes, err = offsetFinder.Find(ctx, orderBys, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, nodes)
+}
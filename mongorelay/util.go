@@ -0,0 +1,127 @@
+package mongorelay
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/relay"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func lookupField[T any](name string) (reflect.StructField, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return reflect.StructField{}, errors.Errorf("T must be a struct or struct pointer, got %s", typ)
+	}
+
+	field, ok := typ.FieldByName(name)
+	if !ok {
+		return reflect.StructField{}, errors.Errorf("missing field %q in %s", name, typ)
+	}
+	return field, nil
+}
+
+// documentKey returns the key of the struct field name in the documents of T,
+// which is the name of the bson tag or the lowercased field name as the default bson codec does
+func documentKey[T any](name string) (string, error) {
+	field, err := lookupField[T](name)
+	if err != nil {
+		return "", err
+	}
+
+	key, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+	switch key {
+	case "-":
+		return "", errors.Errorf("field %q is ignored by bson", name)
+	case "":
+		return strings.ToLower(field.Name), nil
+	}
+	return key, nil
+}
+
+// keysetValue restores the value of the struct field name from the keyset,
+// values of types with text marshaling (e.g. bson.ObjectID) are decoded from the cursor as strings
+func keysetValue[T any](name string, v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	field, err := lookupField[T](name)
+	if err != nil {
+		return nil, err
+	}
+	typ := field.Type
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.String || !reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return v, nil
+	}
+	rv := reflect.New(typ)
+	if err := rv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+		return nil, errors.Wrapf(err, "decode key %q", name)
+	}
+	return rv.Elem().Interface(), nil
+}
+
+// sortOf translates the orderBys into a $sort document
+func sortOf[T any](orderBys []relay.OrderBy, reverse bool) (bson.D, error) {
+	sort := make(bson.D, 0, len(orderBys))
+	for _, orderBy := range orderBys {
+		if orderBy.Fold {
+			return nil, errors.Errorf("fold of %q is not supported by mongorelay", orderBy.Field)
+		}
+		key, err := documentKey[T](orderBy.Field)
+		if err != nil {
+			return nil, err
+		}
+		direction := 1
+		if orderBy.Desc != reverse {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: key, Value: direction})
+	}
+	return sort, nil
+}
+
+// createBoundary builds the condition that matches the documents after the keyset in the order of orderBys,
+// it is the equivalent of the keyset predicate of gormrelay:
+// {$or: [{a: {$gt: 1}}, {a: 1, b: {$gt: 2}}]}
+func createBoundary[T any](orderBys []relay.OrderBy, keyset map[string]any, reverse bool) (bson.M, error) {
+	ors := make(bson.A, 0, len(orderBys))
+	eqs := bson.D{}
+	for _, orderBy := range orderBys {
+		key, err := documentKey[T](orderBy.Field)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := keyset[orderBy.Field]
+		if !ok {
+			return nil, errors.Errorf("missing field %q in keyset", orderBy.Field)
+		}
+		v, err = keysetValue[T](orderBy.Field, v)
+		if err != nil {
+			return nil, err
+		}
+
+		op := "$gt"
+		if orderBy.Desc != reverse {
+			op = "$lt"
+		}
+
+		and := make(bson.D, len(eqs), len(eqs)+1)
+		copy(and, eqs)
+		and = append(and, bson.E{Key: key, Value: bson.M{op: v}})
+		ors = append(ors, and)
+
+		eqs = append(eqs, bson.E{Key: key, Value: v})
+	}
+	return bson.M{"$or": ors}, nil
+}
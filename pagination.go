@@ -2,6 +2,7 @@ package relay
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
@@ -10,6 +11,7 @@ import (
 type OrderBy struct {
 	Field string `json:"field"`
 	Desc  bool   `json:"desc"`
+	Fold  bool   `json:"fold,omitempty"` // order case-insensitively, i.e. by LOWER(field)
 }
 
 type PaginateRequest[T any] struct {
@@ -25,18 +27,30 @@ type Edge[T any] struct {
 	Cursor string `json:"cursor"`
 }
 
+// PageInfo flags follow the Relay spec, including when both after and before bound a window:
+//   - HasNextPage is true if more than first rows remain, or before is set and exists
+//   - HasPreviousPage is true if more than last rows remain, or after is set and exists
+//
+// So a window larger than first (last) reports both flags, the cursors of the trimmed side continue within the window.
 type PageInfo struct {
-	HasNextPage     bool    `json:"hasNextPage"`
-	HasPreviousPage bool    `json:"hasPreviousPage"`
-	StartCursor     *string `json:"startCursor"`
-	EndCursor       *string `json:"endCursor"`
+	HasNextPage     bool       `json:"hasNextPage"`
+	HasPreviousPage bool       `json:"hasPreviousPage"`
+	StartCursor     *string    `json:"startCursor"`
+	EndCursor       *string    `json:"endCursor"`
+	CursorExpiresAt *time.Time `json:"cursorExpiresAt,omitempty"` // set if the cursors expire, e.g. with cursor.WithExpiry
 }
 
 type Connection[T any] struct {
-	Edges      []*Edge[T] `json:"edges,omitempty"`
-	Nodes      []T        `json:"nodes,omitempty"`
-	PageInfo   *PageInfo  `json:"pageInfo,omitempty"`
-	TotalCount *int       `json:"totalCount,omitempty"`
+	Edges             []*Edge[T]       `json:"edges,omitempty"`
+	Nodes             []T              `json:"nodes,omitempty"`
+	PageInfo          *PageInfo        `json:"pageInfo,omitempty"`
+	TotalCount        *int             `json:"totalCount,omitempty"`
+	TotalCountIsExact bool             `json:"totalCountIsExact,omitempty"` // false if TotalCount is nil or estimated
+	Facets            map[string]int   `json:"facets,omitempty"`            // row count per value of the facet field across the whole result set
+	DistinctValues    map[string][]any `json:"distinctValues,omitempty"`    // distinct values per field across the whole result set, bounded by a limit
+	DeletedCount      *int             `json:"deletedCount,omitempty"`      // soft-deleted rows matching the same conditions, not included in TotalCount
+	DroppedNodes      int              `json:"droppedNodes,omitempty"`      // nodes dropped by the lenient node processor
+	EffectiveLimit    int              `json:"effectiveLimit,omitempty"`    // first or last after the middlewares, e.g. clamped by EnsureLimits
 }
 
 type ApplyCursorsRequest struct {
@@ -45,6 +59,7 @@ type ApplyCursorsRequest struct {
 	OrderBys []OrderBy
 	Limit    int
 	FromEnd  bool
+	Stream   bool // set by PaginateStream, the adapter may set LazyEdgeSeq instead of LazyEdges
 }
 
 type LazyEdge[T any] struct {
@@ -53,10 +68,16 @@ type LazyEdge[T any] struct {
 }
 
 type ApplyCursorsResponse[T any] struct {
-	LazyEdges          []*LazyEdge[T]
-	TotalCount         *int
-	HasBeforeOrNext    bool // `before` exists or it's next exists
-	HasAfterOrPrevious bool // `after` exists or it's previous exists
+	LazyEdges             []*LazyEdge[T]
+	LazyEdgeSeq           Seq2[*LazyEdge[T], error] // streamed edges, only if requested with Stream, LazyEdges is nil then
+	TotalCount            *int
+	TotalCountIsEstimated bool // TotalCount is an estimate, the zero value means it is exact
+	HasBeforeOrNext       bool // `before` exists or it's next exists
+	HasAfterOrPrevious    bool // `after` exists or it's previous exists
+	Facets                map[string]int
+	DistinctValues        map[string][]any
+	DeletedCount          *int
+	CursorExpiresAt       *time.Time // when the cursors of the edges expire, nil if they never do
 }
 
 // https://relay.dev/graphql/connections.htm#ApplyCursorsToEdges()
@@ -79,15 +100,8 @@ func paginate[T any](ctx context.Context, req *PaginateRequest[T], applyCursorsF
 	}
 
 	orderBys := req.OrderBys
-	if len(orderBys) > 0 {
-		dups := lo.FindDuplicatesBy(orderBys, func(item OrderBy) string {
-			return item.Field
-		})
-		if len(dups) > 0 {
-			return nil, errors.Errorf("duplicated order by fields %v", lo.Map(dups, func(item OrderBy, _ int) string {
-				return item.Field
-			}))
-		}
+	if err := checkOrderBys(orderBys); err != nil {
+		return nil, err
 	}
 
 	skip := GetSkip(ctx)
@@ -95,12 +109,13 @@ func paginate[T any](ctx context.Context, req *PaginateRequest[T], applyCursorsF
 		return &Connection[T]{}, nil
 	}
 
-	var limit int
+	var effectiveLimit int
 	if req.First != nil {
-		limit = *req.First + 1
+		effectiveLimit = *req.First
 	} else {
-		limit = *req.Last + 1
+		effectiveLimit = *req.Last
 	}
+	limit := effectiveLimit + 1
 
 	rsp, err := applyCursorsFunc(ctx, &ApplyCursorsRequest{
 		Before:   req.Before,
@@ -144,17 +159,49 @@ func paginate[T any](ctx context.Context, req *PaginateRequest[T], applyCursorsF
 		hasPreviousPage = true
 	}
 
-	conn := &Connection[T]{}
+	var droppedNodes int
+	if processor, onError := GetLenientNodeProcessor[T](ctx); processor != nil {
+		retained := make([]*LazyEdge[T], 0, len(lazyEdges))
+		for _, lazyEdge := range lazyEdges {
+			node, err := processor(ctx, lazyEdge.Node)
+			if err != nil {
+				if onError != nil {
+					onError(ctx, lazyEdge.Node, err)
+				}
+				droppedNodes++
+				continue
+			}
+			lazyEdge.Node = node
+			retained = append(retained, lazyEdge)
+		}
+		lazyEdges = retained
+	}
 
-	if !skip.Edges {
-		edges := make([]*Edge[T], len(lazyEdges))
+	conn := &Connection[T]{DroppedNodes: droppedNodes, EffectiveLimit: effectiveLimit}
+
+	mapper := GetEdgeMapper[T](ctx)
+	var edges []*Edge[T]
+	if !skip.Edges || mapper != nil {
+		edges = make([]*Edge[T], len(lazyEdges))
 		for i, lazyEdge := range lazyEdges {
 			cursor, err := lazyEdge.Cursor(ctx, lazyEdge.Node)
 			if err != nil {
 				return nil, err
 			}
 			edges[i] = &Edge[T]{Node: lazyEdge.Node, Cursor: cursor}
+			if mapper != nil {
+				edges[i], err = mapper(ctx, edges[i])
+				if err != nil {
+					return nil, err
+				}
+				if edges[i] == nil {
+					return nil, errors.Errorf("edge mapper returned nil edge at index %d", i)
+				}
+				lazyEdge.Node = edges[i].Node
+			}
 		}
+	}
+	if !skip.Edges {
 		conn.Edges = edges
 	}
 
@@ -168,18 +215,26 @@ func paginate[T any](ctx context.Context, req *PaginateRequest[T], applyCursorsF
 
 	if !skip.TotalCount {
 		conn.TotalCount = rsp.TotalCount
+		conn.TotalCountIsExact = rsp.TotalCount != nil && !rsp.TotalCountIsEstimated
+		conn.DeletedCount = rsp.DeletedCount
 	}
 
-	if !skip.PageInfo {
+	conn.Facets = rsp.Facets
+	conn.DistinctValues = rsp.DistinctValues
+
+	if !skip.PageInfo && GetStableEmptyPageInfo(ctx) && len(rsp.LazyEdges) == 0 &&
+		(rsp.TotalCount == nil || *rsp.TotalCount == 0) {
+		conn.PageInfo = &PageInfo{}
+	} else if !skip.PageInfo {
 		pageInfo := &PageInfo{
 			HasNextPage:     hasNextPage,
 			HasPreviousPage: hasPreviousPage,
 		}
 		if len(lazyEdges) > 0 {
 			var startCursor, endCursor string
-			if len(conn.Edges) > 0 {
-				startCursor = conn.Edges[0].Cursor
-				endCursor = conn.Edges[len(conn.Edges)-1].Cursor
+			if len(edges) > 0 {
+				startCursor = edges[0].Cursor
+				endCursor = edges[len(edges)-1].Cursor
 			} else {
 				startCursor, err = lazyEdges[0].Cursor(ctx, lazyEdges[0].Node)
 				if err != nil {
@@ -197,6 +252,7 @@ func paginate[T any](ctx context.Context, req *PaginateRequest[T], applyCursorsF
 			}
 			pageInfo.StartCursor = &startCursor
 			pageInfo.EndCursor = &endCursor
+			pageInfo.CursorExpiresAt = rsp.CursorExpiresAt
 		}
 		conn.PageInfo = pageInfo
 	}
@@ -204,6 +260,18 @@ func paginate[T any](ctx context.Context, req *PaginateRequest[T], applyCursorsF
 	return conn, nil
 }
 
+func checkOrderBys(orderBys []OrderBy) error {
+	dups := lo.FindDuplicatesBy(orderBys, func(item OrderBy) string {
+		return item.Field
+	})
+	if len(dups) > 0 {
+		return errors.Errorf("duplicated order by fields %v", lo.Map(dups, func(item OrderBy, _ int) string {
+			return item.Field
+		}))
+	}
+	return nil
+}
+
 type Pagination[T any] interface {
 	Paginate(ctx context.Context, req *PaginateRequest[T]) (*Connection[T], error)
 }
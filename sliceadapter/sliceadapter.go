@@ -0,0 +1,291 @@
+// Package sliceadapter paginates an in-memory slice, e.g. for tests or small static datasets.
+// The cursors are encoded the same way as the database adapters do, so they are portable between them.
+package sliceadapter
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+)
+
+// NewKeysetAdapter paginates items with keyset cursors, items must be structs or struct pointers
+// and the orderBys refer to their field names. items is not modified.
+func NewKeysetAdapter[T any](items []T) relay.ApplyCursorsFunc[T] {
+	return cursor.NewKeysetAdapter[T](&KeysetFinder[T]{items: items})
+}
+
+// NewOffsetAdapter paginates items with offset cursors, items must be structs or struct pointers
+// if orderBys are set. items is not modified.
+func NewOffsetAdapter[T any](items []T) relay.ApplyCursorsFunc[T] {
+	return cursor.NewOffsetAdapter[T](&OffsetFinder[T]{items: items})
+}
+
+type KeysetFinder[T any] struct {
+	items []T
+}
+
+func NewKeysetFinder[T any](items []T) *KeysetFinder[T] {
+	return &KeysetFinder[T]{items: items}
+}
+
+func (f *KeysetFinder[T]) Find(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromEnd bool) ([]T, error) {
+	sorted, err := sortItems(f.items, orderBys)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]T, 0, len(sorted))
+	for _, item := range sorted {
+		if after != nil {
+			c, err := compareKeyset(item, *after, orderBys)
+			if err != nil {
+				return nil, err
+			}
+			if c <= 0 {
+				continue
+			}
+		}
+		if before != nil {
+			c, err := compareKeyset(item, *before, orderBys)
+			if err != nil {
+				return nil, err
+			}
+			if c >= 0 {
+				continue
+			}
+		}
+		nodes = append(nodes, item)
+	}
+
+	if len(nodes) > limit {
+		if fromEnd {
+			nodes = nodes[len(nodes)-limit:]
+		} else {
+			nodes = nodes[:limit]
+		}
+	}
+	return nodes, nil
+}
+
+func (f *KeysetFinder[T]) Count(ctx context.Context) (int, error) {
+	return len(f.items), nil
+}
+
+type OffsetFinder[T any] struct {
+	items []T
+}
+
+func NewOffsetFinder[T any](items []T) *OffsetFinder[T] {
+	return &OffsetFinder[T]{items: items}
+}
+
+func (f *OffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]T, error) {
+	sorted, err := sortItems(f.items, orderBys)
+	if err != nil {
+		return nil, err
+	}
+	if skip >= len(sorted) {
+		return []T{}, nil
+	}
+	return sorted[skip:min(skip+limit, len(sorted))], nil
+}
+
+func (f *OffsetFinder[T]) Count(ctx context.Context) (int, error) {
+	return len(f.items), nil
+}
+
+// sortItems returns a sorted copy of items, the sort is stable so that items equal on all orderBys keep their order
+func sortItems[T any](items []T, orderBys []relay.OrderBy) ([]T, error) {
+	sorted := slices.Clone(items)
+	if len(orderBys) == 0 {
+		return sorted, nil
+	}
+
+	var sortErr error
+	slices.SortStableFunc(sorted, func(a, b T) int {
+		for _, orderBy := range orderBys {
+			va, err := fieldValue(a, orderBy.Field)
+			if err != nil {
+				sortErr = err
+				return 0
+			}
+			vb, err := fieldValue(b, orderBy.Field)
+			if err != nil {
+				sortErr = err
+				return 0
+			}
+			if orderBy.Fold {
+				va, vb = fold(va), fold(vb)
+			}
+			c, err := compareValues(va, vb)
+			if err != nil {
+				sortErr = err
+				return 0
+			}
+			if orderBy.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return sorted, nil
+}
+
+// compareKeyset compares the item with the keyset in the order of orderBys
+func compareKeyset[T any](item T, keyset map[string]any, orderBys []relay.OrderBy) (int, error) {
+	for _, orderBy := range orderBys {
+		v, err := fieldValue(item, orderBy.Field)
+		if err != nil {
+			return 0, err
+		}
+		k, ok := keyset[orderBy.Field]
+		if !ok {
+			return 0, errors.Errorf("missing field %q in keyset", orderBy.Field)
+		}
+		if orderBy.Fold {
+			v, k = fold(v), fold(k)
+		}
+		c, err := compareValues(v, k)
+		if err != nil {
+			return 0, err
+		}
+		if orderBy.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+func fieldValue(item any, name string) (any, error) {
+	rv := reflect.Indirect(reflect.ValueOf(item))
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.Errorf("item must be a struct or struct pointer, got %T", item)
+	}
+	fv := rv.FieldByName(name)
+	if !fv.IsValid() {
+		return nil, errors.Errorf("missing field %q in %T", name, item)
+	}
+	return fv.Interface(), nil
+}
+
+// compareValues compares values of the same kind, numbers of different types are compared by value,
+// nil (including nil pointers) is less than any other value.
+func compareValues(a, b any) (int, error) {
+	a, b = deref(a), deref(b)
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+
+	switch a := a.(type) {
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b), nil
+		}
+	case []byte:
+		if b, ok := b.([]byte); ok {
+			return bytes.Compare(a, b), nil
+		}
+	}
+
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case ra.Kind() == reflect.String && rb.Kind() == reflect.String:
+		return strings.Compare(ra.String(), rb.String()), nil
+	case ra.Kind() == reflect.Bool && rb.Kind() == reflect.Bool:
+		return compareBool(ra.Bool(), rb.Bool()), nil
+	case isInt(ra) && isInt(rb):
+		return cmp.Compare(ra.Int(), rb.Int()), nil
+	case isUint(ra) && isUint(rb):
+		return cmp.Compare(ra.Uint(), rb.Uint()), nil
+	case isNumber(ra) && isNumber(rb):
+		return cmp.Compare(toFloat(ra), toFloat(rb)), nil
+	}
+	return 0, errors.Errorf("cannot compare %T with %T", a, b)
+}
+
+// fold lowers strings for the orderBys with Fold by Unicode case mapping, i.e. strings.ToLower.
+// That matches LOWER of postgres with a UTF-8 locale, while LOWER of sqlite only folds ASCII, e.g. "É" stays as is.
+func fold(v any) any {
+	if s, ok := deref(v).(string); ok {
+		return strings.ToLower(s)
+	}
+	return v
+}
+
+func deref(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isNumber(v reflect.Value) bool {
+	return isInt(v) || isUint(v) || v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isInt(v):
+		return float64(v.Int())
+	case isUint(v):
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
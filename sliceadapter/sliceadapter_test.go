@@ -0,0 +1,361 @@
+package sliceadapter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/relay"
+	"github.com/theplant/relay/cursor"
+)
+
+type User struct {
+	ID        int
+	Name      string
+	Age       *int
+	CreatedAt time.Time
+}
+
+func newUsers() []*User {
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	users := make([]*User, 0, 50)
+	for i := 1; i <= 50; i++ {
+		user := &User{
+			ID:        i,
+			Name:      fmt.Sprintf("name%02d", i),
+			CreatedAt: createdAt.Add(time.Duration(i%7) * time.Hour),
+		}
+		if i%10 != 0 {
+			user.Age = lo.ToPtr(i % 5)
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+func TestPaginate(t *testing.T) {
+	users := newUsers()
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "CreatedAt", Desc: false},
+		{Field: "ID", Desc: false},
+	}
+	expected, err := sortItems(users, orderBys)
+	require.NoError(t, err)
+	expectedIDs := lo.Map(expected, func(u *User, _ int) int { return u.ID })
+	require.Equal(t, 4, *expected[0].Age)
+	require.Nil(t, expected[len(expected)-1].Age)
+
+	testCase := func(t *testing.T, f func(items []*User) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			cursor.Base64(f(users)),
+			relay.EnsureLimits[*User](10, 10),
+		)
+
+		var ids []int
+		var after *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After:    after,
+				First:    lo.ToPtr(7),
+				OrderBys: orderBys,
+			})
+			require.NoError(t, err)
+			require.Equal(t, lo.ToPtr(50), conn.TotalCount)
+			require.True(t, conn.TotalCountIsExact) // the adapter does not estimate, so the count is exact
+			for _, edge := range conn.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Equal(t, expectedIDs, ids)
+
+		ids = nil
+		var before *string
+		for {
+			conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				Before:   before,
+				Last:     lo.ToPtr(7),
+				OrderBys: orderBys,
+			})
+			require.NoError(t, err)
+			nodeIDs := lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID })
+			ids = append(nodeIDs, ids...)
+			if !conn.PageInfo.HasPreviousPage {
+				break
+			}
+			before = conn.PageInfo.StartCursor
+		}
+		require.Equal(t, expectedIDs, ids)
+
+		conn, err := p.Paginate(relay.WithSkip(context.Background(), relay.Skip{TotalCount: true}), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(3),
+			OrderBys: orderBys,
+		})
+		require.NoError(t, err)
+		require.Nil(t, conn.TotalCount)
+		require.False(t, conn.TotalCountIsExact)
+		require.Equal(t, expectedIDs[:3], lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(3),
+			OrderBys: []relay.OrderBy{{Field: "Unknown"}},
+		})
+		require.ErrorContains(t, err, `missing field "Unknown"`)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter[*User]) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter[*User]) })
+
+	require.Equal(t, newUsers(), users)
+}
+
+func TestFold(t *testing.T) {
+	users := []*User{
+		{ID: 1, Name: "bob"},
+		{ID: 2, Name: "Alice"},
+		{ID: 3, Name: "alice"},
+		{ID: 4, Name: "Carol"},
+	}
+	orderBys := []relay.OrderBy{
+		{Field: "Name", Fold: true},
+		{Field: "ID", Desc: true},
+	}
+	p := relay.New(NewKeysetAdapter(users))
+
+	conn, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(2), OrderBys: orderBys})
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 2}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+
+	conn, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: conn.PageInfo.EndCursor, First: lo.ToPtr(2), OrderBys: orderBys})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 4}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+
+	// non-ASCII letters are folded too, so "Émile" sorts as "émile" after "éclair"
+	users = []*User{
+		{ID: 1, Name: "Émile"},
+		{ID: 2, Name: "éclair"},
+	}
+	conn, err = relay.New(NewKeysetAdapter(users)).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(2), OrderBys: orderBys})
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 1}, lo.Map(conn.Nodes, func(u *User, _ int) int { return u.ID }))
+}
+
+func TestPaginateStream(t *testing.T) {
+	users := newUsers()
+	orderBys := []relay.OrderBy{{Field: "Name", Desc: true}}
+
+	// streamed moves the buffered edges into LazyEdgeSeq like a streaming adapter does
+	streamed := func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User] {
+		return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
+			rsp, err := next(ctx, req)
+			if err != nil || !req.Stream {
+				return rsp, err
+			}
+			lazyEdges := rsp.LazyEdges
+			rsp.LazyEdges = nil
+			rsp.LazyEdgeSeq = func(yield func(*relay.LazyEdge[*User], error) bool) {
+				for _, edge := range lazyEdges {
+					if !yield(edge, nil) {
+						return
+					}
+				}
+			}
+			return rsp, nil
+		}
+	}
+
+	testCase := func(t *testing.T, applyCursorsFunc relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(applyCursorsFunc)
+		expected, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(20), OrderBys: orderBys})
+		require.NoError(t, err)
+
+		var edges []*relay.Edge[*User]
+		var after *string
+		for {
+			conn, err := relay.PaginateStream(context.Background(), applyCursorsFunc, &relay.PaginateRequest[*User]{
+				After:    after,
+				First:    lo.ToPtr(7),
+				OrderBys: orderBys,
+			})
+			require.NoError(t, err)
+			require.Equal(t, lo.ToPtr(50), conn.TotalCount)
+			require.True(t, conn.TotalCountIsExact)
+			require.Nil(t, conn.PageInfo)
+			conn.Edges(func(edge *relay.Edge[*User], err error) bool {
+				require.NoError(t, err)
+				edges = append(edges, edge)
+				return true
+			})
+			require.NotNil(t, conn.PageInfo)
+			require.Equal(t, after != nil, conn.PageInfo.HasPreviousPage)
+			require.True(t, conn.PageInfo.HasNextPage)
+			require.Equal(t, edges[len(edges)-1].Cursor, *conn.PageInfo.EndCursor)
+			if len(edges) >= 20 {
+				break
+			}
+			after = conn.PageInfo.EndCursor
+		}
+		require.Equal(t, expected.Edges, edges[:20])
+
+		conn, err := relay.PaginateStream(context.Background(), applyCursorsFunc, &relay.PaginateRequest[*User]{After: after, First: lo.ToPtr(100), OrderBys: orderBys})
+		require.NoError(t, err)
+		var rest int
+		conn.Edges(func(edge *relay.Edge[*User], err error) bool {
+			rest++
+			return true
+		})
+		require.Equal(t, 50-14, rest)
+		require.False(t, conn.PageInfo.HasNextPage)
+		require.True(t, conn.PageInfo.HasPreviousPage)
+
+		conn, err = relay.PaginateStream(context.Background(), applyCursorsFunc, &relay.PaginateRequest[*User]{First: lo.ToPtr(7), OrderBys: orderBys})
+		require.NoError(t, err)
+		var count int
+		conn.Edges(func(edge *relay.Edge[*User], err error) bool {
+			count++
+			return count < 3
+		})
+		require.Equal(t, 3, count)
+		require.Nil(t, conn.PageInfo)
+
+		// the lenient node processor drops the nodes like Paginate does
+		var failedIDs []int
+		ctx := relay.WithLenientNodeProcessor(context.Background(), func(ctx context.Context, u *User) (*User, error) {
+			if u.ID%3 == 0 {
+				return nil, errors.New("divisible by 3")
+			}
+			return u, nil
+		}, func(ctx context.Context, u *User, err error) {
+			failedIDs = append(failedIDs, u.ID)
+		})
+		lenient, err := p.Paginate(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(7), OrderBys: orderBys})
+		require.NoError(t, err)
+		failedIDs = nil
+		conn, err = relay.PaginateStream(ctx, applyCursorsFunc, &relay.PaginateRequest[*User]{First: lo.ToPtr(7), OrderBys: orderBys})
+		require.NoError(t, err)
+		edges = nil
+		conn.Edges(func(edge *relay.Edge[*User], err error) bool {
+			require.NoError(t, err)
+			edges = append(edges, edge)
+			return true
+		})
+		require.Equal(t, lenient.Edges, edges)
+		require.Equal(t, lenient.DroppedNodes, conn.DroppedNodes)
+		require.Equal(t, []int{48, 45}, failedIDs)
+		require.Equal(t, lenient.PageInfo, conn.PageInfo)
+
+		_, err = relay.PaginateStream(context.Background(), applyCursorsFunc, &relay.PaginateRequest[*User]{Last: lo.ToPtr(7), OrderBys: orderBys})
+		require.ErrorContains(t, err, "first must be set for streaming")
+
+		conn, err = relay.PaginateStream(context.Background(), applyCursorsFunc, &relay.PaginateRequest[*User]{First: lo.ToPtr(3), OrderBys: []relay.OrderBy{{Field: "Unknown"}}})
+		if err == nil {
+			conn.Edges(func(edge *relay.Edge[*User], e error) bool {
+				err = e
+				return true
+			})
+		}
+		require.ErrorContains(t, err, `missing field "Unknown"`)
+	}
+
+	t.Run("buffered", func(t *testing.T) { testCase(t, cursor.Base64(NewKeysetAdapter(users))) })
+	t.Run("streamed", func(t *testing.T) { testCase(t, cursor.Base64(streamed(NewKeysetAdapter(users)))) })
+	t.Run("offset", func(t *testing.T) { testCase(t, cursor.Base64(streamed(NewOffsetAdapter(users)))) })
+}
+
+func TestCursorBuilder(t *testing.T) {
+	users := newUsers()
+	orderBys := []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID"}}
+	p := relay.New(cursor.Base64(NewKeysetAdapter(users)))
+
+	expected, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5), OrderBys: orderBys})
+	require.NoError(t, err)
+
+	var calls int
+	ctx := relay.WithCursorBuilder(context.Background(), func(ctx context.Context, nodes []*User, orderBys []relay.OrderBy) ([]string, error) {
+		calls++
+		keys := lo.Map(orderBys, func(orderBy relay.OrderBy, _ int) string { return orderBy.Field })
+		cursors := make([]string, len(nodes))
+		for i, node := range nodes {
+			c, err := cursor.EncodeKeysetCursor(node, keys)
+			if err != nil {
+				return nil, err
+			}
+			cursors[i] = c
+		}
+		return cursors, nil
+	})
+	conn, err := p.Paginate(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(5), OrderBys: orderBys})
+	require.NoError(t, err)
+	require.Equal(t, expected.Edges, conn.Edges)
+	require.Equal(t, expected.PageInfo, conn.PageInfo)
+	require.Equal(t, 1, calls)
+
+	ctx = relay.WithCursorBuilder(context.Background(), func(ctx context.Context, nodes []*User, orderBys []relay.OrderBy) ([]string, error) {
+		return []string{"x"}, nil
+	})
+	_, err = p.Paginate(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(5), OrderBys: orderBys})
+	require.ErrorContains(t, err, "cursor builder returned 1 cursors for 6 nodes")
+}
+
+func TestEdgeMapper(t *testing.T) {
+	users := newUsers()
+	orderBys := []relay.OrderBy{{Field: "ID"}}
+	p := relay.New(cursor.Base64(NewKeysetAdapter(users)))
+
+	ctx := relay.WithEdgeMapper(context.Background(), func(ctx context.Context, edge *relay.Edge[*User]) (*relay.Edge[*User], error) {
+		node := *edge.Node
+		node.Name = "mapped:" + node.Name
+		return &relay.Edge[*User]{Node: &node, Cursor: "c" + edge.Cursor}, nil
+	})
+	conn, err := p.Paginate(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(3), OrderBys: orderBys})
+	require.NoError(t, err)
+	require.Equal(t, []string{"mapped:name01", "mapped:name02", "mapped:name03"}, lo.Map(conn.Nodes, func(u *User, _ int) string { return u.Name }))
+	require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+	require.Equal(t, conn.Edges[2].Cursor, *conn.PageInfo.EndCursor)
+	require.Equal(t, byte('c'), conn.Edges[0].Cursor[0])
+	require.Equal(t, "name01", users[0].Name)
+
+	// the mapped nodes and cursors are used even if the edges are skipped
+	conn, err = p.Paginate(relay.WithSkip(ctx, relay.Skip{Edges: true}), &relay.PaginateRequest[*User]{First: lo.ToPtr(3), OrderBys: orderBys})
+	require.NoError(t, err)
+	require.Nil(t, conn.Edges)
+	require.Equal(t, "mapped:name01", conn.Nodes[0].Name)
+	require.Equal(t, byte('c'), (*conn.PageInfo.StartCursor)[0])
+
+	ctx = relay.WithEdgeMapper(context.Background(), func(ctx context.Context, edge *relay.Edge[*User]) (*relay.Edge[*User], error) {
+		return nil, errors.New("mapper failed")
+	})
+	_, err = p.Paginate(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(3), OrderBys: orderBys})
+	require.ErrorContains(t, err, "mapper failed")
+
+	ctx = relay.WithEdgeMapper(context.Background(), func(ctx context.Context, edge *relay.Edge[*User]) (*relay.Edge[*User], error) {
+		if edge.Node.ID == 2 {
+			return nil, nil
+		}
+		return edge, nil
+	})
+	_, err = p.Paginate(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(3), OrderBys: orderBys})
+	require.ErrorContains(t, err, "edge mapper returned nil edge at index 1")
+
+	stream, err := relay.PaginateStream(ctx, cursor.Base64(NewKeysetAdapter(users)), &relay.PaginateRequest[*User]{First: lo.ToPtr(3), OrderBys: orderBys})
+	require.NoError(t, err)
+	var streamed int
+	stream.Edges(func(edge *relay.Edge[*User], err error) bool {
+		if err != nil {
+			require.ErrorContains(t, err, "edge mapper returned nil edge at index 1")
+			return false
+		}
+		streamed++
+		return true
+	})
+	require.Equal(t, 1, streamed)
+	require.Nil(t, stream.PageInfo)
+}
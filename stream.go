@@ -0,0 +1,163 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Seq2 is an iterator over pairs, it has the same shape as iter.Seq2, so it can be ranged over since Go 1.23
+type Seq2[K, V any] func(yield func(K, V) bool)
+
+// EachLazyEdge calls f with each lazy edge of the response, e.g. to wrap the cursor functions in a cursor middleware.
+// The buffered LazyEdges are visited immediately, the streamed LazyEdgeSeq is wrapped so f is called as the edges are yielded.
+func (rsp *ApplyCursorsResponse[T]) EachLazyEdge(f func(edge *LazyEdge[T])) {
+	for _, edge := range rsp.LazyEdges {
+		f(edge)
+	}
+	if seq := rsp.LazyEdgeSeq; seq != nil {
+		rsp.LazyEdgeSeq = func(yield func(*LazyEdge[T], error) bool) {
+			seq(func(edge *LazyEdge[T], err error) bool {
+				if err == nil && edge != nil {
+					f(edge)
+				}
+				return yield(edge, err)
+			})
+		}
+	}
+}
+
+// StreamConnection is the result of PaginateStream, the edges are yielded one by one instead of being held in memory.
+//   - TotalCount is counted before streaming, so it is available right away unless skipped
+//   - PageInfo is nil until Edges has been ranged over to the end, it is filled when the last edge is yielded
+//
+// Edges can only be ranged over once, an error stops the iteration and is yielded with a nil edge.
+type StreamConnection[T any] struct {
+	Edges             Seq2[*Edge[T], error]
+	TotalCount        *int
+	TotalCountIsExact bool
+	PageInfo          *PageInfo
+	DroppedNodes      int // nodes dropped by the lenient node processor so far
+}
+
+// PaginateStream is like Paginate of New(applyCursorsFunc), but streams the edges, so a large page is never held in memory at once
+// if the adapter supports streaming (it sets ApplyCursorsResponse.LazyEdgeSeq), otherwise the buffered edges are yielded.
+// Only First is supported, since Last would require the whole page to reverse it. Pagination middlewares are not applied,
+// while cursor middlewares from the context, the node processor and the edge mapper are.
+// The lenient node processor is applied per node as Paginate does: a node whose processing fails is not yielded
+// but still counts towards first, onError is called with it and it is added to DroppedNodes.
+func PaginateStream[T any](ctx context.Context, applyCursorsFunc ApplyCursorsFunc[T], req *PaginateRequest[T]) (*StreamConnection[T], error) {
+	if req.First == nil {
+		return nil, errors.New("first must be set for streaming")
+	}
+	if req.Last != nil {
+		return nil, errors.New("last cannot be used for streaming")
+	}
+	if *req.First < 0 {
+		return nil, errors.New("first must be a non-negative integer")
+	}
+	if err := checkOrderBys(req.OrderBys); err != nil {
+		return nil, err
+	}
+
+	first := *req.First
+	applyCursorsFunc = chainCursorMiddlewares(CursorMiddlewaresFromContext[T](ctx))(applyCursorsFunc)
+	rsp, err := applyCursorsFunc(ctx, &ApplyCursorsRequest{
+		Before:   req.Before,
+		After:    req.After,
+		OrderBys: req.OrderBys,
+		Limit:    first + 1,
+		Stream:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	skip := GetSkip(ctx)
+	conn := &StreamConnection[T]{}
+	if !skip.TotalCount {
+		conn.TotalCount = rsp.TotalCount
+		conn.TotalCountIsExact = rsp.TotalCount != nil && !rsp.TotalCountIsEstimated
+	}
+
+	seq := rsp.LazyEdgeSeq
+	if seq == nil {
+		seq = func(yield func(*LazyEdge[T], error) bool) {
+			for _, edge := range rsp.LazyEdges {
+				if !yield(edge, nil) {
+					return
+				}
+			}
+		}
+	}
+
+	processor := GetNodeProcessor[T](ctx)
+	lenientProcessor, onError := GetLenientNodeProcessor[T](ctx)
+	mapper := GetEdgeMapper[T](ctx)
+	conn.Edges = func(yield func(*Edge[T], error) bool) {
+		pageInfo := &PageInfo{
+			HasPreviousPage: req.After != nil && rsp.HasAfterOrPrevious,
+			HasNextPage:     req.Before != nil && rsp.HasBeforeOrNext,
+			CursorExpiresAt: rsp.CursorExpiresAt,
+		}
+		var count, retained int
+		var failed, stopped bool
+		seq(func(lazyEdge *LazyEdge[T], err error) bool {
+			if err == nil && count >= first {
+				pageInfo.HasNextPage = true
+				return false
+			}
+			var edge *Edge[T]
+			if err == nil && processor != nil {
+				lazyEdge.Node, err = processor(ctx, lazyEdge.Node)
+			}
+			if err == nil && lenientProcessor != nil {
+				node, processErr := lenientProcessor(ctx, lazyEdge.Node)
+				if processErr != nil {
+					if onError != nil {
+						onError(ctx, lazyEdge.Node, processErr)
+					}
+					count++
+					conn.DroppedNodes++
+					return true
+				}
+				lazyEdge.Node = node
+			}
+			if err == nil {
+				var cursor string
+				cursor, err = lazyEdge.Cursor(ctx, lazyEdge.Node)
+				edge = &Edge[T]{Node: lazyEdge.Node, Cursor: cursor}
+			}
+			if err == nil && mapper != nil {
+				edge, err = mapper(ctx, edge)
+				if err == nil && edge == nil {
+					err = errors.Errorf("edge mapper returned nil edge at index %d", count)
+				}
+			}
+			if err != nil {
+				failed = true
+				yield(nil, err)
+				return false
+			}
+
+			count++
+			retained++
+			if retained == 1 {
+				pageInfo.StartCursor = &edge.Cursor
+			}
+			pageInfo.EndCursor = &edge.Cursor
+			if !yield(edge, nil) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if !failed && !stopped {
+			if retained == 0 {
+				pageInfo.CursorExpiresAt = nil
+			}
+			conn.PageInfo = pageInfo
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,58 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Tail fetches up to limit nodes strictly after the cursor, e.g. for a client polling a feed for the rows added since its last poll.
+// It differs from First/After in that:
+//   - the orderBys are always ascending (Desc is cleared, also on those appended by the middlewares), so that the new rows, e.g. with a larger ID or creation time, come after the cursor
+//   - an empty poll keeps the cursor, i.e. PageInfo.StartCursor and EndCursor are the given cursor if no node is after it,
+//     so the client can always poll again with EndCursor
+//
+// An empty cursor tails from the first node. HasNextPage tells whether there are more new nodes than limit.
+// Use a keyset adapter with orderBys that new rows are appended by, e.g. the primary key, offsets shift as rows are inserted.
+func Tail[T any](ctx context.Context, p Pagination[T], after string, limit int, orderBys ...OrderBy) (*Connection[T], error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be greater than 0")
+	}
+
+	// the middlewares may append descending orderBys, e.g. EnsurePrimaryOrderBy, so they are cleared again before the adapter
+	ctx = context.WithValue(ctx, ctxCursorMiddlewares{}, append([]CursorMiddleware[T]{ascendingOrderBys[T]}, CursorMiddlewaresFromContext[T](ctx)...))
+
+	req := &PaginateRequest[T]{
+		First:    &limit,
+		OrderBys: clearDesc(orderBys),
+	}
+	if after != "" {
+		req.After = &after
+	}
+	conn, err := p.Paginate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if after != "" && conn.PageInfo != nil && conn.PageInfo.EndCursor == nil {
+		conn.PageInfo.StartCursor = &after
+		conn.PageInfo.EndCursor = &after
+	}
+	return conn, nil
+}
+
+func ascendingOrderBys[T any](next ApplyCursorsFunc[T]) ApplyCursorsFunc[T] {
+	return func(ctx context.Context, req *ApplyCursorsRequest) (*ApplyCursorsResponse[T], error) {
+		req.OrderBys = clearDesc(req.OrderBys)
+		return next(ctx, req)
+	}
+}
+
+func clearDesc(orderBys []OrderBy) []OrderBy {
+	ascending := make([]OrderBy, len(orderBys))
+	for i, orderBy := range orderBys {
+		orderBy.Desc = false
+		ascending[i] = orderBy
+	}
+	return ascending
+}